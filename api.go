@@ -13,8 +13,15 @@ type InterfaceApi interface {
 	IsErrorResponse() bool
 }
 
+// Optional API interface, implement it so the devtool library can fill the response, such as the Gin resource routes
+type InterfaceApiData interface {
+	WithData(data interface{}) InterfaceApi
+	WithError(err error) InterfaceApi
+}
+
 const (
-	ConfigPathApiField = "api.field"
+	ConfigPathApiField       = "api.field"
+	ConfigPathApiErrorStatus = "api.error_status" // If true, the legacy errors such as a string are responded with 400 instead of 200
 )
 
 var (
@@ -52,7 +59,7 @@ type library_api_response struct {
 	Code    int         `json:"code"`
 	Message string      `json:"message"`
 	Data    interface{} `json:"data"`
-	Error   interface{} `json:"error"`
+	Error   interface{} `json:"error"` // Usually *ApiError, an error is converted by ApiErrorOf, other values such as a string are responded as they are
 }
 
 // Initialization
@@ -73,6 +80,7 @@ func (l LibraryApi) Success() interface{} {
 // Returns the structure of the error response
 func (l LibraryApi) Error() interface{} {
 	l.Response.Success = false
+	l.Response.Error = fillApiErrorResponse(l.Response.Error, &l.Response.Code, &l.Response.Message)
 	if len(l.Response.Message) == 0 {
 		l.Response.Message = "Error"
 	}
@@ -91,9 +99,26 @@ func (l LibraryApi) Pagination(p InterfacePagination) interface{} {
 	return data
 }
 
+// Set the data of the response
+func (l LibraryApi) WithData(data interface{}) InterfaceApi {
+	l.Response.Data = data
+	return l
+}
+
+// Set the error of the response, it is converted by ApiErrorFrom
+func (l LibraryApi) WithError(err error) InterfaceApi {
+	l.Response.Error = ApiErrorFrom(err)
+	return l
+}
+
+// The HTTP status of the error, 200 if there is no error or the error is a legacy error such as a string
+func (l LibraryApi) HTTPStatus() int {
+	return apiErrorHTTPStatus(l.Response.Error)
+}
+
 // Determine whether the current response is an error
 func (l LibraryApi) IsErrorResponse() bool {
-	if l.Response.Error != nil {
+	if ApiErrorOf(l.Response.Error) != nil {
 		return true
 	}
 	return false
@@ -101,9 +126,9 @@ func (l LibraryApi) IsErrorResponse() bool {
 
 // API interceptor, modify the returned fields
 func (l LibraryApi) ModifyApiFieldName(data interface{}) (interface{}, error) {
-	fieldMap := Config[InterfaceConfig]{}.Get().GetStringMap(ConfigPathApiField)
-	// If the user does not define the interceptor mapping field, the original value is returned
-	if len(fieldMap) == 0 {
+	r := newApiFieldRenamer()
+	// If the user does not define the interceptor mapping field or casing, the original value is returned
+	if r.empty() {
 		return data, nil
 	}
 
@@ -112,37 +137,11 @@ func (l LibraryApi) ModifyApiFieldName(data interface{}) (interface{}, error) {
 		return data, err
 	}
 
-	var m map[string]interface{}
+	var m interface{}
 	err = json.Unmarshal(b, &m)
 	if err != nil {
 		return data, err
 	}
 
-	l.recursionReplaceResponseKeys(m, fieldMap)
-
-	return m, nil
-}
-
-// Recursive method to determine whether the structure is a slice or an object and modify it
-func (l LibraryApi) recursionReplaceResponseKeys(jsonMap interface{}, fieldMap map[string]interface{}) {
-	switch jv := jsonMap.(type) {
-	case map[string]interface{}:
-		for k, v := range jv {
-			switch jv2 := v.(type) {
-			case map[string]interface{}:
-				l.recursionReplaceResponseKeys(jv2, fieldMap)
-			case []interface{}:
-				l.recursionReplaceResponseKeys(jv2, fieldMap)
-			default:
-				if fv, ok := fieldMap[k]; ok {
-					jv[fv.(string)] = v
-					delete(jv, k)
-				}
-			}
-		}
-	case []interface{}:
-		for k, _ := range jv {
-			l.recursionReplaceResponseKeys(jv[k], fieldMap)
-		}
-	}
+	return r.rename(m), nil
 }
@@ -0,0 +1,179 @@
+package d
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"gorm.io/gorm"
+)
+
+// Optional API interface, implement it so Gin responds with the HTTP status of the response
+type InterfaceApiStatus interface {
+	HTTPStatus() int
+}
+
+// The client closed the connection before the response, the same as nginx
+const StatusClientClosedRequest = 499
+
+// Typed API error, it is the error of the response
+// Example:
+// a := d.Api[d.LibraryApi]{}.Get()
+// a.Response.Error = d.NewApiError(http.StatusForbidden, "permission denied")
+type ApiError struct {
+	Code    int             `json:"code"` // Business code, default is the HTTP status
+	Status  int             `json:"-"`    // HTTP status
+	Message string          `json:"message"`
+	Details interface{}     `json:"details,omitempty"`
+	Fields  []ApiFieldError `json:"fields,omitempty"` // Field-level validation errors
+}
+
+// Field-level validation error
+type ApiFieldError struct {
+	Field   string `json:"field"` // JSON path of the field, such as address.street
+	Rule    string `json:"rule"`  // The failed validation rule, such as required
+	Param   string `json:"param"` // The parameter of the rule, such as 3 of min=3
+	Message string `json:"message"`
+}
+
+func (e *ApiError) Error() string {
+	return e.Message
+}
+
+// Create an API error, the message defaults to the status text
+func NewApiError(status int, message string) *ApiError {
+	if message == "" {
+		message = http.StatusText(status)
+	}
+	return &ApiError{Code: status, Status: status, Message: message}
+}
+
+// Convert the error of a response to an API error, for the errors set directly, such as LibraryApi.Response.Error
+// An error is converted by ApiErrorFrom but keeps its message, an unknown error is a 400 error
+// A string is the message of a 400 error, other values are the details of a 400 error
+// Example: ApiErrorOf("user not found") returns a 400 error with the message user not found
+func ApiErrorOf(v interface{}) *ApiError {
+	switch e := v.(type) {
+	case nil:
+		return nil
+	case *ApiError:
+		return e
+	case ApiError:
+		return &e
+	case string:
+		return NewApiError(http.StatusBadRequest, e)
+	case error:
+		apiErr := ApiErrorFrom(e)
+		if errors.As(e, new(*ApiError)) || apiErr.Status != http.StatusInternalServerError {
+			return apiErr
+		}
+		return NewApiError(http.StatusBadRequest, e.Error())
+	}
+	apiErr := NewApiError(http.StatusBadRequest, "")
+	apiErr.Details = v
+	return apiErr
+}
+
+// Whether the error of a response is a legacy error, such as a string or a map, it is responded as it is
+func isLegacyApiError(v interface{}) bool {
+	switch v.(type) {
+	case nil, *ApiError, ApiError, error:
+		return false
+	}
+	return true
+}
+
+// The HTTP status of the error response of an api, see InterfaceApiStatus
+func apiHTTPStatus(a InterfaceApi) int {
+	if s, ok := a.(InterfaceApiStatus); ok {
+		return s.HTTPStatus()
+	}
+	return legacyApiErrorStatus()
+}
+
+// The HTTP status of the legacy errors and of the apis without InterfaceApiStatus, 200 as before, or 400 if api.error_status is true
+func legacyApiErrorStatus() int {
+	conf := Config[InterfaceConfig]{}.Get()
+	if conf.GetBool(ConfigPathApiErrorStatus) {
+		return http.StatusBadRequest
+	}
+	return http.StatusOK
+}
+
+// Fill the error response of an envelope, the error is converted by ApiErrorOf unless it is a legacy error
+// The message and the code are only filled if they are not set
+func fillApiErrorResponse(v interface{}, code *int, message *string) interface{} {
+	if isLegacyApiError(v) {
+		return v
+	}
+	apiErr := ApiErrorOf(v)
+	if apiErr == nil {
+		return nil
+	}
+	if len(*message) == 0 {
+		*message = apiErr.Message
+	}
+	if *code == 0 {
+		*code = apiErr.Code
+	}
+	return apiErr
+}
+
+// The HTTP status of the error of a response, 200 if there is no error
+func apiErrorHTTPStatus(v interface{}) int {
+	if isLegacyApiError(v) {
+		return legacyApiErrorStatus()
+	}
+	apiErr := ApiErrorOf(v)
+	switch {
+	case apiErr == nil:
+		return http.StatusOK
+	case apiErr.Status == 0:
+		return http.StatusBadRequest
+	}
+	return apiErr.Status
+}
+
+// Convert an error to an API error with a sensible HTTP status
+// The message of an unknown error is not exposed, it is reported as 500 Internal Server Error
+func ApiErrorFrom(err error) *ApiError {
+	if err == nil {
+		return nil
+	}
+
+	var apiErr *ApiError
+	if errors.As(err, &apiErr) {
+		return apiErr
+	}
+
+	// The field names are the names reported by the validator, see Gin.ShouldBindJSON for JSON names
+	if apiErr = NewValidationApiError(err, nil, ""); apiErr != nil {
+		return apiErr
+	}
+
+	var (
+		syntaxErr     *json.SyntaxError
+		unmarshalErr  *json.UnmarshalTypeError
+		fuzzyQueryErr *FuzzyQueryError
+		filterErr     *FilterError
+		sortErr       *SortError
+	)
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return NewApiError(http.StatusNotFound, err.Error())
+	case errors.Is(err, context.DeadlineExceeded):
+		return NewApiError(http.StatusGatewayTimeout, "")
+	case errors.Is(err, context.Canceled):
+		return NewApiError(StatusClientClosedRequest, "client closed request")
+	// Malformed or empty request body, or invalid query parameters
+	case errors.As(err, &syntaxErr), errors.As(err, &unmarshalErr),
+		errors.As(err, &fuzzyQueryErr), errors.As(err, &filterErr), errors.As(err, &sortErr),
+		errors.Is(err, ErrCursorInvalid), errors.Is(err, ErrExportFormatNotSupported), errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return NewApiError(http.StatusBadRequest, err.Error())
+	case errors.Is(err, ErrDatabaseNotReady):
+		return NewApiError(http.StatusServiceUnavailable, "")
+	}
+	return NewApiError(http.StatusInternalServerError, "")
+}
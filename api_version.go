@@ -0,0 +1,399 @@
+package d
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Optional API interface, implement it to choose or fill the response by the request, such as the version of the API
+// header is the header of the response, it is written before the response
+type InterfaceApiRequest interface {
+	ForRequest(r *http.Request, header http.Header) InterfaceApi
+}
+
+// Optional API interface, implement it to report the deprecation of the version in the response, the headers are written by LibraryApiVersions
+type InterfaceApiDeprecation interface {
+	WithDeprecation(d *ApiDeprecation) InterfaceApi
+}
+
+var (
+	FieldNameApiVersionHeader = "X-API-Version" // Header of the version of the API, in both the request and the response
+	FieldNameApiRequestID     = "X-Request-ID"  // Header of the request ID, in both the request and the response
+)
+
+// Deprecation of an API version, it is reported by the Deprecation, Sunset and Link headers
+type ApiDeprecation struct {
+	Since   time.Time // When the version was deprecated, zero means it is deprecated without a date
+	Sunset  time.Time // When the version will be removed, zero means no date is planned
+	Link    string    // Documentation of the migration
+	Message string    // Warning for the clients
+}
+
+// The zero times are omitted
+func (d ApiDeprecation) MarshalJSON() ([]byte, error) {
+	m := make(map[string]interface{}, 4)
+	if !d.Since.IsZero() {
+		m["since"] = d.Since
+	}
+	if !d.Sunset.IsZero() {
+		m["sunset"] = d.Sunset
+	}
+	if d.Link != "" {
+		m["link"] = d.Link
+	}
+	if d.Message != "" {
+		m["message"] = d.Message
+	}
+	return json.Marshal(m)
+}
+
+// Version of the API
+type ApiVersion struct {
+	Api         InterfaceApi    // Envelope of the responses of the version
+	Deprecation *ApiDeprecation // Nil means the version is not deprecated
+}
+
+// Api library with versioned envelopes, the version is chosen per request by the URL prefix, such as /v2/users or /api/v2/users with the prefix /api, or the X-API-Version header
+// The responses without a request, and the requests asking for an unknown version, use the default version
+// Example:
+//
+//	d.Api[d.LibraryApiVersions]{}.Init(d.LibraryApiVersions{
+//		Default: "v1",
+//		Versions: map[string]d.ApiVersion{
+//			"v1": {Api: d.LibraryApi{}, Deprecation: &d.ApiDeprecation{Sunset: sunset, Message: "please upgrade to v2"}},
+//			"v2": {Api: d.LibraryApiV2{}},
+//		},
+//	})
+//	api := d.Api[d.InterfaceApi]{}.Get()
+type LibraryApiVersions struct {
+	Versions map[string]ApiVersion // Versions by name, such as v1
+	Default  string
+	Prefix   string // Path before the version, such as /api, if empty, the version is the first path segment
+
+	data    interface{} // Set by WithData, applied to the chosen version
+	hasData bool
+	err     error // Set by WithError, applied to the chosen version
+}
+
+// Initialization, v1 is LibraryApi and v2 is LibraryApiV2, v1 is the default
+func (l LibraryApiVersions) Init() {
+	Api[LibraryApiVersions]{}.Init(LibraryApiVersions{
+		Default: "v1",
+		Versions: map[string]ApiVersion{
+			"v1": {Api: LibraryApi{}},
+			"v2": {Api: LibraryApiV2{}},
+		},
+	})
+}
+
+// Choose the version of the request and write the version and deprecation headers
+func (l LibraryApiVersions) ForRequest(r *http.Request, header http.Header) InterfaceApi {
+	name := l.version(r)
+	v, ok := l.Versions[name]
+	if !ok {
+		return l.apply(nil)
+	}
+
+	header.Set(FieldNameApiVersionHeader, name)
+	if d := v.Deprecation; d != nil {
+		// https://www.rfc-editor.org/rfc/rfc9745 and https://www.rfc-editor.org/rfc/rfc8594
+		if d.Since.IsZero() {
+			header.Set("Deprecation", "true")
+		} else {
+			header.Set("Deprecation", "@"+strconv.FormatInt(d.Since.Unix(), 10))
+		}
+		if !d.Sunset.IsZero() {
+			header.Set("Sunset", d.Sunset.UTC().Format(http.TimeFormat))
+		}
+		// The api may be chosen more than once for a response, such as for every event of SSE
+		if link := "<" + d.Link + `>; rel="deprecation"`; d.Link != "" && !slices.Contains(header.Values("Link"), link) {
+			header.Add("Link", link)
+		}
+		if warning := `299 - "` + strings.ReplaceAll(d.Message, `"`, `'`) + `"`; d.Message != "" && !slices.Contains(header.Values("Warning"), warning) {
+			header.Add("Warning", warning)
+		}
+	}
+
+	a := v.Api
+	if ad, ok := a.(InterfaceApiDeprecation); ok && v.Deprecation != nil {
+		a = ad.WithDeprecation(v.Deprecation)
+	}
+	if ar, ok := a.(InterfaceApiRequest); ok {
+		a = ar.ForRequest(r, header)
+	}
+	return l.apply(a)
+}
+
+// The version asked by the request
+// The path segment after the prefix takes precedence over the header, without a prefix the header takes precedence over the first path segment
+func (l LibraryApiVersions) version(r *http.Request) string {
+	if r == nil {
+		return l.Default
+	}
+	path := strings.TrimPrefix(r.URL.Path, "/")
+	if prefix := strings.Trim(l.Prefix, "/"); prefix != "" {
+		if rest, ok := strings.CutPrefix(path, prefix+"/"); ok {
+			if v, _, _ := strings.Cut(rest, "/"); l.hasVersion(v) {
+				return v
+			}
+		}
+	}
+	if v := r.Header.Get(FieldNameApiVersionHeader); l.hasVersion(v) {
+		return v
+	}
+	if v, _, _ := strings.Cut(path, "/"); l.Prefix == "" && l.hasVersion(v) {
+		return v
+	}
+	return l.Default
+}
+
+func (l LibraryApiVersions) hasVersion(name string) bool {
+	_, ok := l.Versions[name]
+	return ok && name != ""
+}
+
+// Apply the data and error to the api, the default version is used if a is nil
+func (l LibraryApiVersions) apply(a InterfaceApi) InterfaceApi {
+	if a == nil {
+		a = l.Versions[l.Default].Api
+		if a == nil {
+			a = LibraryApi{}
+		}
+	}
+	if ad, ok := a.(InterfaceApiData); ok {
+		if l.hasData {
+			a = ad.WithData(l.data)
+		}
+		if l.err != nil {
+			a = a.(InterfaceApiData).WithError(l.err)
+		}
+	}
+	return a
+}
+
+// Returns the structure of a successful response of the default version
+func (l LibraryApiVersions) Success() interface{} {
+	return l.apply(nil).Success()
+}
+
+// Returns the structure of the error response of the default version
+func (l LibraryApiVersions) Error() interface{} {
+	return l.apply(nil).Error()
+}
+
+// Returns the structure of the pagination response of the default version
+func (l LibraryApiVersions) Pagination(p InterfacePagination) interface{} {
+	return l.apply(nil).Pagination(p)
+}
+
+// Set the data of the response, it is applied to the chosen version
+func (l LibraryApiVersions) WithData(data interface{}) InterfaceApi {
+	l.data = data
+	l.hasData = true
+	return l
+}
+
+// Set the error of the response, it is applied to the chosen version
+func (l LibraryApiVersions) WithError(err error) InterfaceApi {
+	l.err = err
+	return l
+}
+
+// The HTTP status of the error, 200 if there is no error
+func (l LibraryApiVersions) HTTPStatus() int {
+	a := l.apply(nil)
+	if !a.IsErrorResponse() {
+		return http.StatusOK
+	}
+	return apiHTTPStatus(a)
+}
+
+// Determine whether the current response is an error
+func (l LibraryApiVersions) IsErrorResponse() bool {
+	return l.apply(nil).IsErrorResponse()
+}
+
+// Api library of the v2 envelope, it is the envelope of LibraryApi with metadata
+// The pagination is in the metadata together with the links of the pages, the data is the list
+type LibraryApiV2 struct {
+	Response library_api_v2_response
+	request  *url.URL // The URL of the request, for the links of the pages
+}
+
+type library_api_v2_response struct {
+	Success bool        `json:"success"`
+	Code    int         `json:"code"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data"`
+	Error   interface{} `json:"error,omitempty"` // The same as the error of LibraryApi
+	Meta    ApiMeta     `json:"meta"`
+}
+
+// Metadata of the v2 envelope
+type ApiMeta struct {
+	RequestID   string                 `json:"request_id,omitempty"`
+	Version     string                 `json:"version,omitempty"`
+	Timestamp   time.Time              `json:"timestamp"`
+	Pagination  map[string]interface{} `json:"pagination,omitempty"`
+	Links       map[string]string      `json:"links,omitempty"` // Links of the pages, such as self, first, prev, next and last
+	Deprecation *ApiDeprecation        `json:"deprecation,omitempty"`
+}
+
+// Initialization
+func (l LibraryApiV2) Init() {
+	Api[LibraryApiV2]{}.Init(LibraryApiV2{})
+}
+
+// Fill the metadata from the request, the request ID is read from the X-Request-ID header or generated
+// The version is read from the header written by LibraryApiVersions
+func (l LibraryApiV2) ForRequest(r *http.Request, header http.Header) InterfaceApi {
+	id := header.Get(FieldNameApiRequestID)
+	if id == "" && r != nil {
+		id = r.Header.Get(FieldNameApiRequestID)
+	}
+	if id == "" {
+		id = newRequestID()
+	}
+	header.Set(FieldNameApiRequestID, id)
+
+	l.Response.Meta.RequestID = id
+	l.Response.Meta.Version = header.Get(FieldNameApiVersionHeader)
+	if r != nil {
+		l.request = r.URL
+	}
+	return l
+}
+
+// Returns the structure of a successful response
+func (l LibraryApiV2) Success() interface{} {
+	l.Response.Success = true
+	if len(l.Response.Message) == 0 {
+		l.Response.Message = "Success"
+	}
+	return l.response()
+}
+
+// Returns the structure of the error response
+func (l LibraryApiV2) Error() interface{} {
+	l.Response.Success = false
+	l.Response.Error = fillApiErrorResponse(l.Response.Error, &l.Response.Code, &l.Response.Message)
+	if len(l.Response.Message) == 0 {
+		l.Response.Message = "Error"
+	}
+	return l.response()
+}
+
+// Returns the structure of the pagination response, the data is the list and the rest is in the metadata
+func (l LibraryApiV2) Pagination(p InterfacePagination) interface{} {
+	l.Response.Success = true
+	if len(l.Response.Message) == 0 {
+		l.Response.Message = "Success"
+	}
+	m := p.ToMap()
+	l.Response.Data = m[FieldNamePaginationList]
+	delete(m, FieldNamePaginationList)
+	l.Response.Meta.Pagination = m
+	l.Response.Meta.Links = l.links(m)
+	return l.response()
+}
+
+// Report the deprecation of the version in the metadata
+func (l LibraryApiV2) WithDeprecation(d *ApiDeprecation) InterfaceApi {
+	l.Response.Meta.Deprecation = d
+	return l
+}
+
+// Set the data of the response
+func (l LibraryApiV2) WithData(data interface{}) InterfaceApi {
+	l.Response.Data = data
+	return l
+}
+
+// Set the error of the response, it is converted by ApiErrorFrom
+func (l LibraryApiV2) WithError(err error) InterfaceApi {
+	l.Response.Error = ApiErrorFrom(err)
+	return l
+}
+
+// The HTTP status of the error, 200 if there is no error or the error is a legacy error such as a string
+func (l LibraryApiV2) HTTPStatus() int {
+	return apiErrorHTTPStatus(l.Response.Error)
+}
+
+// Determine whether the current response is an error
+func (l LibraryApiV2) IsErrorResponse() bool {
+	return ApiErrorOf(l.Response.Error) != nil
+}
+
+func (l LibraryApiV2) response() interface{} {
+	if l.Response.Meta.Timestamp.IsZero() {
+		l.Response.Meta.Timestamp = time.Now().UTC()
+	}
+	data, _ := LibraryApi{}.ModifyApiFieldName(l.Response)
+	return data
+}
+
+// The links of the pages, the page or cursor query parameter of the request is replaced
+func (l LibraryApiV2) links(m map[string]interface{}) map[string]string {
+	if l.request == nil {
+		return nil
+	}
+	link := func(key, value string) string {
+		u := *l.request
+		q := u.Query()
+		q.Set(key, value)
+		u.RawQuery = q.Encode()
+		return u.RequestURI()
+	}
+
+	links := map[string]string{"self": l.request.RequestURI()}
+	if next, ok := m[FieldNamePaginationNextCursor].(string); ok && next != "" {
+		links["next"] = link(FieldNamePaginationCursor, next)
+	}
+	if prev, ok := m[FieldNamePaginationPrevCursor].(string); ok && prev != "" {
+		links["prev"] = link(FieldNamePaginationCursor, prev)
+	}
+
+	page, ok := m[FieldNamePaginationPage].(int)
+	if !ok {
+		return links
+	}
+	links["first"] = link(FieldNamePaginationPage, "1")
+	if page > 1 {
+		links["prev"] = link(FieldNamePaginationPage, strconv.Itoa(page-1))
+	}
+	// Without a count mode, the total is exact and has_more is not reported
+	total, hasTotal := m[FieldNamePaginationTotal].(int)
+	pageSize, _ := m[FieldNamePaginationPageSize].(int)
+	mode, hasMode := m[FieldNamePaginationCountMode].(CountMode)
+	hasMore, ok := m[FieldNamePaginationHasMore].(bool)
+	if !ok && !hasMode && hasTotal {
+		hasMore = page*pageSize < total
+	}
+	if hasMore {
+		links["next"] = link(FieldNamePaginationPage, strconv.Itoa(page+1))
+	}
+	// The last page is only known by an exact total
+	if hasTotal && pageSize > 0 && (!hasMode || mode == CountModeExact) {
+		last := (total + pageSize - 1) / pageSize
+		if last < 1 {
+			last = 1
+		}
+		links["last"] = link(FieldNamePaginationPage, strconv.Itoa(last))
+	}
+	return links
+}
+
+// A random request ID of 16 bytes in hex
+func newRequestID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,83 @@
+package d
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Casing of the keys of the API, the keys of the responses are converted to it and the keys of the requests are converted back to snake_case
+type FieldCasing string
+
+const (
+	FieldCasingSnake  FieldCasing = "snake"  // user_name
+	FieldCasingCamel  FieldCasing = "camel"  // userName
+	FieldCasingPascal FieldCasing = "pascal" // UserName
+	FieldCasingKebab  FieldCasing = "kebab"  // user-name
+)
+
+const (
+	ConfigPathApiCasing = "api.casing" // Empty means the keys are not converted, the api.field config takes precedence
+)
+
+// Convert the key to the casing, an unknown casing returns the key as it is
+// Example: ConvertFieldCasing("user_id", d.FieldCasingCamel) returns userId
+func ConvertFieldCasing(key string, casing FieldCasing) string {
+	var sep string
+	switch casing {
+	case FieldCasingSnake:
+		sep = "_"
+	case FieldCasingKebab:
+		sep = "-"
+	case FieldCasingCamel, FieldCasingPascal:
+	default:
+		return key
+	}
+
+	words := splitFieldWords(key)
+	if len(words) == 0 {
+		return key
+	}
+	for i, v := range words {
+		v = strings.ToLower(v)
+		if sep == "" && (i > 0 || casing == FieldCasingPascal) {
+			r := []rune(v)
+			r[0] = unicode.ToUpper(r[0])
+			v = string(r)
+		}
+		words[i] = v
+	}
+	return strings.Join(words, sep)
+}
+
+// Split the key into words, such as user_name, user-name, userName and UserID
+// An acronym is one word, HTTPStatus is split into HTTP and Status
+func splitFieldWords(key string) []string {
+	var words []string
+	runes := []rune(key)
+	start := 0
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		if r == '_' || r == '-' || r == ' ' {
+			if i > start {
+				words = append(words, string(runes[start:i]))
+			}
+			start = i + 1
+			continue
+		}
+		if i == start || !unicode.IsUpper(r) {
+			continue
+		}
+		prev := runes[i-1]
+		// userName: a lowercase letter or digit followed by an uppercase letter
+		// HTTPStatus: the last letter of an acronym followed by a lowercase letter
+		if unicode.IsLower(prev) || unicode.IsDigit(prev) ||
+			(unicode.IsUpper(prev) && i+1 < len(runes) && unicode.IsLower(runes[i+1])) {
+			words = append(words, string(runes[start:i]))
+			start = i
+		}
+	}
+	if start < len(runes) {
+		words = append(words, string(runes[start:]))
+	}
+	return words
+}
@@ -2,6 +2,7 @@ package d
 
 import (
 	"fmt"
+	"sync"
 
 	"github.com/fsnotify/fsnotify"
 	"github.com/spf13/viper"
@@ -17,8 +18,16 @@ type InterfaceConfig interface {
 	Set(key string, value interface{}) error
 }
 
+// Optional config interface, implement it to read lists such as database.replicas, otherwise they are read as comma-separated strings
+type InterfaceConfigStringSlice interface {
+	GetStringSlice(key string) []string
+}
+
 var (
 	config InterfaceConfig // Global variable, stores the initialized interface, if not initialized, it is nil
+
+	configChangeListeners      []func(e fsnotify.Event)
+	configChangeListenersMutex sync.RWMutex
 )
 
 // Config library unified access entry
@@ -70,11 +79,25 @@ func (l LibraryViper) Init() {
 		panic(err)
 	}
 
-	conf.OnConfigChange(l.OnConfigChange)
+	conf.OnConfigChange(func(e fsnotify.Event) {
+		l.OnConfigChange(e)
+		configChangeListenersMutex.RLock()
+		defer configChangeListenersMutex.RUnlock()
+		for _, f := range configChangeListeners {
+			f(e)
+		}
+	})
 	conf.WatchConfig()
 	Config[LibraryViper]{}.Init(LibraryViper{Viper: conf})
 }
 
+// Add a listener called after OnConfigChange, used by the devtool library to apply changed config live
+func (l LibraryViper) AddConfigChangeListener(f func(e fsnotify.Event)) {
+	configChangeListenersMutex.Lock()
+	defer configChangeListenersMutex.Unlock()
+	configChangeListeners = append(configChangeListeners, f)
+}
+
 // Get the int. If there is no value, get the default value of the setting.
 func (l LibraryViper) GetIntWithDefault(key string, default_value int) int {
 	Config[LibraryViper]{}.Get().Viper.SetDefault(key, default_value)
@@ -97,6 +120,11 @@ func (l LibraryViper) GetStringMap(key string) map[string]interface{} {
 	return Config[LibraryViper]{}.Get().Viper.GetStringMap(key)
 }
 
+// Get string slice
+func (l LibraryViper) GetStringSlice(key string) []string {
+	return Config[LibraryViper]{}.Get().Viper.GetStringSlice(key)
+}
+
 // Get string map
 func (l LibraryViper) GetBool(key string) bool {
 	return Config[LibraryViper]{}.Get().Viper.GetBool(key)
@@ -0,0 +1,176 @@
+package d
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"sync/atomic"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Connection state of the database, used by health endpoints
+type DatabaseState int32
+
+const (
+	DatabaseStateDisconnected DatabaseState = iota
+	DatabaseStateConnecting
+	DatabaseStateReady
+	DatabaseStateFailed
+)
+
+func (s DatabaseState) String() string {
+	switch s {
+	case DatabaseStateConnecting:
+		return "connecting"
+	case DatabaseStateReady:
+		return "ready"
+	case DatabaseStateFailed:
+		return "failed"
+	}
+	return "disconnected"
+}
+
+var (
+	databaseState atomic.Int32
+)
+
+var (
+	ErrDatabaseNotReady = errors.New("database is not ready")
+)
+
+// Error returned when the database cannot be connected, Err is the error of the last attempt or of the context
+type DatabaseConnectError struct {
+	Attempts int
+	Err      error
+}
+
+func (e *DatabaseConnectError) Error() string {
+	return fmt.Sprintf("failed to connect to database after %d attempts: %v", e.Attempts, e.Err)
+}
+
+func (e *DatabaseConnectError) Unwrap() error {
+	return e.Err
+}
+
+// Connect to the database and register it, retrying with exponential backoff and jitter
+// The retry stops when ctx is done or database.connect_max_attempts is reached
+// Example:
+// ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+// defer cancel()
+// err := d.LibraryGorm{}.Connect(ctx)
+func (l LibraryGorm) Connect(ctx context.Context) error {
+	databaseState.Store(int32(DatabaseStateConnecting))
+
+	if l.Driver == "" {
+		l.Driver = Config[InterfaceConfig]{}.Get().GetStringWithDefault(ConfigPathDatabaseDriver, DatabaseDriverMySQL)
+	}
+	dialect, err := GetDatabaseDialect(l.Driver)
+	if err != nil {
+		databaseState.Store(int32(DatabaseStateFailed))
+		return &DatabaseConnectError{Err: fmt.Errorf("%w: %s", err, l.Driver)}
+	}
+	if l.OpenDsn == "" {
+		dbHost := Config[InterfaceConfig]{}.Get().GetStringWithDefault(ConfigPathDatabaseHost, "")
+		dbName := Config[InterfaceConfig]{}.Get().GetStringWithDefault(ConfigPathDatabaseName, "")
+		dbUser := Config[InterfaceConfig]{}.Get().GetStringWithDefault(ConfigPathDatabaseUser, "")
+		dbPassword := Config[InterfaceConfig]{}.Get().GetStringWithDefault(ConfigPathDatabasePassword, "")
+		l.OpenDsn = dialect.Dsn(dbHost, dbName, dbUser, dbPassword)
+	}
+	if l.Open == nil {
+		l.Open = func(dialector gorm.Dialector, opts ...gorm.Option) (db *gorm.DB, err error) {
+			return gorm.Open(dialector, opts...)
+		}
+	}
+
+	maxAttempts := Config[InterfaceConfig]{}.Get().GetIntWithDefault(ConfigPathDatabaseConnectMaxAttempts, DefaultDatabaseConnectMaxAttempts)
+	interval := time.Millisecond * time.Duration(Config[InterfaceConfig]{}.Get().GetIntWithDefault(ConfigPathDatabaseConnectInterval, DefaultDatabaseConnectInterval))
+	// database.timeout_reconnection_interval was the interval between two attempts, it is the default of the maximum interval
+	reconnectionInterval := Config[InterfaceConfig]{}.Get().GetIntWithDefault(ConfigPathTimeoutReconnectionInterval, DefaultDatabaseTimeoutReconnectionInterval)
+	maxInterval := time.Second * time.Duration(Config[InterfaceConfig]{}.Get().GetIntWithDefault(ConfigPathDatabaseConnectMaxInterval, reconnectionInterval))
+
+	for attempt := 1; ; attempt++ {
+		db, err := l.open(dialect)
+		if err == nil {
+			Database[LibraryGorm]{}.Init(LibraryGorm{
+				DB: db,
+			})
+			databaseState.Store(int32(DatabaseStateReady))
+			l.watchPoolConfig()
+			return nil
+		}
+
+		if maxAttempts > 0 && attempt >= maxAttempts {
+			databaseState.Store(int32(DatabaseStateFailed))
+			return &DatabaseConnectError{Attempts: attempt, Err: err}
+		}
+
+		wait := l.backoff(interval, maxInterval, attempt)
+		fmt.Printf("Error encountered while connecting to database: %v, automatically reconnecting after %v\n", err.Error(), wait)
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			databaseState.Store(int32(DatabaseStateFailed))
+			return &DatabaseConnectError{Attempts: attempt, Err: ctx.Err()}
+		case <-timer.C:
+		}
+	}
+}
+
+// Open the database with its pool config and replicas, the opened database is closed if a step fails
+func (l LibraryGorm) open(dialect InterfaceDialect) (*gorm.DB, error) {
+	db, err := l.Open(dialect.Open(l.OpenDsn), &gorm.Config{})
+	if err != nil {
+		return nil, err
+	}
+	sqlDB, err := db.DB()
+	if err == nil {
+		l.applyPoolConfig(sqlDB)
+		err = l.useReplicas(db, dialect)
+	}
+	if err != nil {
+		if closer, ok := db.ConnPool.(io.Closer); ok {
+			_ = closer.Close()
+		}
+		return nil, err
+	}
+	return db, nil
+}
+
+// The wait before the next attempt, interval doubles each attempt up to max_interval, half of it is random
+func (l LibraryGorm) backoff(interval, max_interval time.Duration, attempt int) time.Duration {
+	wait := interval
+	for i := 1; i < attempt && wait < max_interval; i++ {
+		wait *= 2
+	}
+	if wait > max_interval {
+		wait = max_interval
+	}
+	if wait <= 0 {
+		return 0
+	}
+	half := wait / 2
+	return half + time.Duration(rand.Int63n(int64(wait-half)+1))
+}
+
+// Get the connection state of the database
+func (l LibraryGorm) State() DatabaseState {
+	return DatabaseState(databaseState.Load())
+}
+
+// Check whether the database is connected and reachable, used by readiness endpoints
+func (l LibraryGorm) Ping(ctx context.Context) error {
+	if l.State() != DatabaseStateReady {
+		return ErrDatabaseNotReady
+	}
+	sqlDB, err := Database[LibraryGorm]{}.Get().DB.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.PingContext(ctx)
+}
@@ -0,0 +1,277 @@
+package d
+
+import (
+	"database/sql/driver"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Ordering column of keyset pagination
+type CursorColumn struct {
+	Name string // Database column name
+	Desc bool   // Descending order
+}
+
+var (
+	ErrCursorInvalid       = errors.New("invalid pagination cursor")
+	ErrCursorNoColumns     = errors.New("keyset pagination requires at least one ordering column")
+	ErrCursorUnknownColumn = errors.New("keyset pagination column does not exist in the model")
+	ErrCursorNullable      = errors.New("keyset pagination column can be NULL")
+	ErrCursorColumnType    = errors.New("keyset pagination column type is not supported")
+)
+
+// Opaque cursor, encoded as base64 JSON in the query parameter
+type cursor struct {
+	Values   []string `json:"v"`
+	Types    []string `json:"t"`
+	Backward bool     `json:"b,omitempty"` // Fetch the rows before the cursor instead of after it
+}
+
+func decodeCursor(raw string, columns int) (c cursor, err error) {
+	b, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return c, ErrCursorInvalid
+	}
+	if err = json.Unmarshal(b, &c); err != nil {
+		return c, ErrCursorInvalid
+	}
+	if len(c.Values) != columns || len(c.Types) != columns {
+		return c, ErrCursorInvalid
+	}
+	return c, nil
+}
+
+func (c cursor) encode() string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// Whether the cursor points to a position, the first page has no position
+func (c cursor) isSet() bool {
+	return len(c.Values) > 0
+}
+
+// Convert the values stored in the cursor back to the type they were read as
+func (c cursor) args() ([]interface{}, error) {
+	args := make([]interface{}, len(c.Values))
+	for i, v := range c.Values {
+		var err error
+		switch c.Types[i] {
+		case "time":
+			args[i], err = time.Parse(time.RFC3339Nano, v)
+		case "int":
+			args[i], err = strconv.ParseInt(v, 10, 64)
+		case "uint":
+			args[i], err = strconv.ParseUint(v, 10, 64)
+		case "float":
+			args[i], err = strconv.ParseFloat(v, 64)
+		case "bool":
+			args[i], err = strconv.ParseBool(v)
+		case "bytes":
+			args[i], err = base64.RawURLEncoding.DecodeString(v)
+		default:
+			args[i] = v
+		}
+		if err != nil {
+			return nil, ErrCursorInvalid
+		}
+	}
+	return args, nil
+}
+
+// Apply keyset conditions and ordering
+// (c1 > v1) OR (c1 = v1 AND c2 > v2) OR ... , the comparison is flipped by descending order and backward direction
+func (c cursor) scope(columns []CursorColumn) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if c.isSet() {
+			condition, err := c.condition(columns)
+			if err != nil {
+				db.AddError(err)
+				return db
+			}
+			db = db.Where(condition)
+		}
+
+		for _, v := range columns {
+			db = db.Order(clause.OrderByColumn{Column: clause.Column{Name: v.Name}, Desc: v.Desc != c.Backward})
+		}
+		return db
+	}
+}
+
+// The keyset condition of the rows after the cursor, or before it if the cursor is backward
+func (c cursor) condition(columns []CursorColumn) (clause.Expression, error) {
+	args, err := c.args()
+	if err != nil {
+		return nil, err
+	}
+
+	var or []clause.Expression
+	for i := range columns {
+		var and []clause.Expression
+		for j := 0; j < i; j++ {
+			and = append(and, clause.Eq{Column: clause.Column{Name: columns[j].Name}, Value: args[j]})
+		}
+		if columns[i].Desc != c.Backward {
+			and = append(and, clause.Lt{Column: clause.Column{Name: columns[i].Name}, Value: args[i]})
+		} else {
+			and = append(and, clause.Gt{Column: clause.Column{Name: columns[i].Name}, Value: args[i]})
+		}
+		or = append(or, clause.And(and...))
+	}
+	return clause.Or(or...), nil
+}
+
+// Trim the extra row, restore the order of a backward page and generate the next and previous cursors
+func (c cursor) paginate(tx *gorm.DB, columns []CursorColumn, data_list_pointer interface{}, page_size int) (int, string, string, error) {
+	list, hasMore, err := truncateList(data_list_pointer, page_size)
+	if err != nil {
+		return page_size, "", "", err
+	}
+	if c.Backward {
+		swap := reflect.Swapper(list.Interface())
+		for i, j := 0, list.Len()-1; i < j; i, j = i+1, j-1 {
+			swap(i, j)
+		}
+	}
+	if list.Len() == 0 {
+		return page_size, "", "", nil
+	}
+
+	var next_cursor, prev_cursor string
+	// Forward: more rows after this page, Backward: we came from the page after this one
+	if hasMore || c.Backward {
+		next, err := cursorAt(tx, columns, list.Index(list.Len()-1))
+		if err != nil {
+			return page_size, "", "", err
+		}
+		next_cursor = next.encode()
+	}
+	// Forward: we came from the page before this one, Backward: more rows before this page
+	if (c.isSet() && !c.Backward) || (c.Backward && hasMore) {
+		prev, err := cursorAt(tx, columns, list.Index(0))
+		if err != nil {
+			return page_size, "", "", err
+		}
+		prev.Backward = true
+		prev_cursor = prev.encode()
+	}
+	return page_size, next_cursor, prev_cursor, nil
+}
+
+// Check the ordering columns of the model of data_list_pointer
+// A column that can be NULL is rejected, the keyset conditions never match NULL, so its rows would be skipped
+func checkCursorColumns(db *gorm.DB, columns []CursorColumn, data_list_pointer interface{}) error {
+	model := db.Statement.Model
+	if model == nil {
+		model = data_list_pointer
+	}
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(model); err != nil {
+		return err
+	}
+	for _, v := range columns {
+		field := stmt.Schema.LookUpField(v.Name)
+		if field == nil {
+			return fmt.Errorf("%w: %s", ErrCursorUnknownColumn, v.Name)
+		}
+		if cursorNullable(field.FieldType) && !field.NotNull && !field.PrimaryKey {
+			return fmt.Errorf("%w: %s, declare it with the not null tag if it cannot", ErrCursorNullable, v.Name)
+		}
+	}
+	return nil
+}
+
+// Whether the type can hold NULL, such as a pointer, sql.NullString or gorm.DeletedAt
+func cursorNullable(t reflect.Type) bool {
+	if t.Kind() == reflect.Pointer {
+		return true
+	}
+	if valuer, ok := reflect.New(t).Interface().(driver.Valuer); ok {
+		v, err := valuer.Value()
+		return err == nil && v == nil
+	}
+	return false
+}
+
+// Read the ordering column values of a row into a cursor
+func cursorAt(tx *gorm.DB, columns []CursorColumn, row reflect.Value) (c cursor, err error) {
+	if tx.Statement.Schema == nil {
+		return c, ErrCursorUnknownColumn
+	}
+	row = reflect.Indirect(row)
+	for _, v := range columns {
+		field := tx.Statement.Schema.LookUpField(v.Name)
+		if field == nil {
+			return c, fmt.Errorf("%w: %s", ErrCursorUnknownColumn, v.Name)
+		}
+		value, _ := field.ValueOf(tx.Statement.Context, row)
+		s, t, err := cursorValue(value)
+		if err != nil {
+			return c, fmt.Errorf("%w: %s", err, v.Name)
+		}
+		c.Values = append(c.Values, s)
+		c.Types = append(c.Types, t)
+	}
+	return c, nil
+}
+
+// Stringify a column value together with its type, so it is compared as the same type on the next page
+// The values of types such as decimals are their database values
+func cursorValue(value interface{}) (string, string, error) {
+	value = indirectCursorValue(value)
+	if valuer, ok := value.(driver.Valuer); ok {
+		v, err := valuer.Value()
+		if err != nil {
+			return "", "", err
+		}
+		value = indirectCursorValue(v)
+	}
+
+	switch v := value.(type) {
+	case nil:
+		return "", "", ErrCursorNullable
+	case time.Time:
+		return v.Format(time.RFC3339Nano), "time", nil
+	case []byte:
+		return base64.RawURLEncoding.EncodeToString(v), "bytes", nil
+	}
+
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(rv.Int(), 10), "int", nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(rv.Uint(), 10), "uint", nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(rv.Float(), 'g', -1, 64), "float", nil
+	case reflect.Bool:
+		return strconv.FormatBool(rv.Bool()), "bool", nil
+	case reflect.String:
+		return rv.String(), "string", nil
+	}
+	return "", "", fmt.Errorf("%w: %T", ErrCursorColumnType, value)
+}
+
+// The value a pointer points to, nil for a nil pointer
+func indirectCursorValue(value interface{}) interface{} {
+	rv := reflect.ValueOf(value)
+	for rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+	if !rv.IsValid() {
+		return nil
+	}
+	return rv.Interface()
+}
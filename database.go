@@ -1,12 +1,12 @@
 package d
 
 import (
-	"fmt"
+	"context"
 	"net/http"
+	"net/url"
 	"strconv"
-	"time"
+	"strings"
 
-	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
 )
 
@@ -16,17 +16,34 @@ type InterfaceDatabase interface {
 }
 
 const (
-	ConfigPathDatabaseHost                = "database.host"
-	ConfigPathDatabaseName                = "database.name"
-	ConfigPathDatabaseUser                = "database.user"
-	ConfigPathDatabasePassword            = "database.password"
-	ConfigPathTimeoutReconnectionInterval = "database.timeout_reconnection_interval"
-	ConfigPathInsertInitializationData    = "database.insert_initialization_data"
+	ConfigPathDatabaseDriver               = "database.driver"
+	ConfigPathDatabaseHost                 = "database.host"
+	ConfigPathDatabaseName                 = "database.name"
+	ConfigPathDatabaseUser                 = "database.user"
+	ConfigPathDatabasePassword             = "database.password"
+	ConfigPathTimeoutReconnectionInterval  = "database.timeout_reconnection_interval" // Seconds, deprecated, it was the interval between two connection attempts, now the default of database.connect_max_interval
+	ConfigPathDatabaseConnectMaxAttempts   = "database.connect_max_attempts"          // 0 means retrying until the context is done
+	ConfigPathDatabaseConnectInterval      = "database.connect_interval"              // Milliseconds, the interval after the first failed attempt, it doubles after each attempt
+	ConfigPathDatabaseConnectMaxInterval   = "database.connect_max_interval"          // Seconds, the maximum interval between two connection attempts
+	ConfigPathInsertInitializationData     = "database.insert_initialization_data"
+	ConfigPathDatabaseMaxOpenConns         = "database.max_open_conns"         // 0 means unlimited
+	ConfigPathDatabaseMaxIdleConns         = "database.max_idle_conns"         // 0 means no idle connections are kept
+	ConfigPathDatabaseConnMaxLifetime      = "database.conn_max_lifetime"      // Seconds, 0 means connections are reused forever
+	ConfigPathDatabaseConnMaxIdleTime      = "database.conn_max_idle_time"     // Seconds, 0 means connections are not closed for being idle
+	ConfigPathDatabaseReplicas             = "database.replicas"               // Hosts of the read replicas, they share the name, user and password of the primary
+	ConfigPathDatabaseReplicaCheckInterval = "database.replica_check_interval" // Seconds, how often a replica that is down is checked
 )
 
 var (
 	database                                   InterfaceDatabase // Global variable, stores the initialized interface, if not initialized, it is nil
 	DefaultDatabaseTimeoutReconnectionInterval = 10
+	DefaultDatabaseConnectMaxAttempts          = 0
+	DefaultDatabaseConnectInterval             = 500
+	DefaultDatabaseMaxOpenConns                = 0 // The defaults are the same as database/sql
+	DefaultDatabaseMaxIdleConns                = 2
+	DefaultDatabaseConnMaxLifetime             = 0
+	DefaultDatabaseConnMaxIdleTime             = 0
+	DefaultDatabaseReplicaCheckInterval        = 5
 )
 
 // ORM library unified access entry
@@ -47,44 +64,31 @@ func (d Database[T]) Get() T {
 
 type LibraryGorm struct {
 	*gorm.DB
+	Driver  string // The name of the registered dialect, if empty, the database.driver config is used, default is mysql
 	OpenDsn string
 	Open    func(dialector gorm.Dialector, opts ...gorm.Option) (db *gorm.DB, err error)
 }
 
-// Initialization
+// Initialization, blocks until the database is connected, panics if database.connect_max_attempts is exceeded
 func (l LibraryGorm) Init() {
-	if l.OpenDsn == "" {
-		dbHost := Config[InterfaceConfig]{}.Get().GetStringWithDefault(ConfigPathDatabaseHost, "")
-		dbName := Config[InterfaceConfig]{}.Get().GetStringWithDefault(ConfigPathDatabaseName, "")
-		dbUser := Config[InterfaceConfig]{}.Get().GetStringWithDefault(ConfigPathDatabaseUser, "")
-		dbPassword := Config[InterfaceConfig]{}.Get().GetStringWithDefault(ConfigPathDatabasePassword, "")
-		l.OpenDsn = dbUser + ":" + dbPassword + "@tcp(" + dbHost + ")/" + dbName + "?charset=utf8mb4&parseTime=True&loc=Local"
-	}
-	if l.Open == nil {
-		l.Open = func(dialector gorm.Dialector, opts ...gorm.Option) (db *gorm.DB, err error) {
-			return gorm.Open(dialector, opts...)
-		}
+	if err := l.Connect(context.Background()); err != nil {
+		panic(err)
 	}
-	db, err := l.Open(mysql.Open(l.OpenDsn), &gorm.Config{})
-	if err != nil {
-		// Auto Reconnected
-		fmt.Printf("Error encountered while connecting to database: %v, automatically reconnecting after 10 seconds", err.Error())
-		tri := Config[InterfaceConfig]{}.Get().GetIntWithDefault(ConfigPathTimeoutReconnectionInterval, DefaultDatabaseTimeoutReconnectionInterval)
-		time.Sleep(time.Second * time.Duration(tri))
-		l.Init()
-		return
-	}
-
-	Database[LibraryGorm]{}.Init(LibraryGorm{
-		DB: db,
-	})
 }
 
-// Only support MySQL now
-// Generate lazy query parameters based on parameters and value
-// Example : GenerateFuzzyQueries(tx, map[string]string{"name": "John", "sex": "female"})
-func (l LibraryGorm) GenerateFuzzyQueries(tx *gorm.DB, fields map[string]string) (*gorm.DB, error) {
-	whereClause, args, err := MySQL{}.GenerateFuzzyQueries(fields)
+// Generate lazy query parameters based on parameters and value, the dialect is chosen by the driver of tx
+// The columns are checked against allowed_columns and the schema of the model of tx, allowed_columns is required if tx has no model
+// Example : GenerateFuzzyQueries(tx, map[string]string{"name": "John", "sex": "female"}, "name", "sex")
+func (l LibraryGorm) GenerateFuzzyQueries(tx *gorm.DB, fields map[string]string, allowed_columns ...string) (*gorm.DB, error) {
+	dialect, err := GetDatabaseDialect(tx.Dialector.Name())
+	if err != nil {
+		return nil, err
+	}
+	fields, err = l.checkFuzzyQueryColumns(tx, fields, allowed_columns)
+	if err != nil {
+		return nil, err
+	}
+	whereClause, args, err := dialect.GenerateFuzzyQueries(fields)
 	if err != nil {
 		return nil, err
 	}
@@ -92,32 +96,169 @@ func (l LibraryGorm) GenerateFuzzyQueries(tx *gorm.DB, fields map[string]string)
 	return tx, nil
 }
 
+// Check the columns of the fuzzy query, the field names of the model are converted to column names
+func (l LibraryGorm) checkFuzzyQueryColumns(tx *gorm.DB, fields map[string]string, allowed_columns []string) (map[string]string, error) {
+	if fields == nil {
+		return nil, ErrFuzzyQueryNilFields
+	}
+
+	if len(allowed_columns) > 0 {
+		allowed := make(map[string]bool, len(allowed_columns))
+		for _, v := range allowed_columns {
+			allowed[v] = true
+		}
+		for k, v := range fields {
+			if len(v) > 0 && !allowed[k] {
+				return nil, &FuzzyQueryError{Column: k, Err: ErrFuzzyQueryColumnNotAllowed}
+			}
+		}
+	}
+
+	// Without a model, the columns cannot be checked, so they must be allowed explicitly
+	if tx.Statement.Model == nil || tx.Statement.Parse(tx.Statement.Model) != nil {
+		if len(allowed_columns) == 0 {
+			for _, v := range fields {
+				if len(v) > 0 {
+					return nil, ErrFuzzyQueryNoAllowedColumns
+				}
+			}
+		}
+		return fields, nil
+	}
+
+	var m = make(map[string]string, len(fields))
+	for k, v := range fields {
+		if len(v) == 0 {
+			m[k] = v
+			continue
+		}
+		// A qualified column such as products.name must be of the table of the model, unless it is allowed
+		table, name, qualified := strings.Cut(k, ".")
+		if !qualified {
+			table, name = "", k
+		}
+		field := tx.Statement.Schema.LookUpField(name)
+		if field == nil || field.DBName == "" || (qualified && table != tx.Statement.Table) {
+			// An allowed column may not be a field of the model, such as a column of a joined table
+			if len(allowed_columns) > 0 {
+				m[k] = v
+				continue
+			}
+			return nil, &FuzzyQueryError{Column: k, Err: ErrFuzzyQueryColumnNotAllowed}
+		}
+		if qualified {
+			m[table+"."+field.DBName] = v
+		} else {
+			m[field.DBName] = v
+		}
+	}
+	return m, nil
+}
+
+// Count the rows of the query by the count mode, CountModeSkip returns 0
+// CountModeEstimated is supported by dialects implementing InterfaceDialectEstimateCount, others count exactly
+// Returns the mode that ran, which is CountModeExact when the estimate is not supported
+func (l LibraryGorm) CountWithMode(tx *gorm.DB, mode CountMode, data_list_pointer interface{}) (total int64, ran CountMode, err error) {
+	switch mode {
+	case CountModeSkip:
+		return 0, CountModeSkip, nil
+	case CountModeEstimated:
+		if dialect, err := GetDatabaseDialect(tx.Dialector.Name()); err == nil {
+			if e, ok := dialect.(InterfaceDialectEstimateCount); ok {
+				total, err = e.EstimateCount(tx, data_list_pointer)
+				return total, CountModeEstimated, err
+			}
+		}
+	}
+	err = tx.Count(&total).Error
+	return total, CountModeExact, err
+}
+
 // Paginate
 // https://gorm.io/docs/scopes.html#Pagination
 func (l LibraryGorm) PaginateV2(r *http.Request) func(db *gorm.DB) (page, page_size int) {
+	return l.paginate(r.URL.Query())
+}
+
+func (l LibraryGorm) paginate(values url.Values) func(db *gorm.DB) (page, page_size int) {
 	return func(db *gorm.DB) (page, page_size int) {
+		page, page_size = l.pageAndPageSize(values)
+		offset := (page - 1) * page_size
+		db.Offset(offset).Limit(page_size)
+		return page, page_size
+	}
+}
+
+// Read the page and page size from the query
+// The page size defaults to pagination.default_page_size and is capped at pagination.max_page_size, a config that is not positive is ignored
+func (l LibraryGorm) pageAndPageSize(values url.Values) (page, page_size int) {
+	page, _ = strconv.Atoi(values.Get(FieldNamePaginationPage))
+	if page <= 0 {
+		page = 1
+	}
+
+	return page, l.pageSize(values.Get(FieldNamePaginationPageSize))
+}
+
+func (l LibraryGorm) pageSize(value string) int {
+	maxPageSize := Config[InterfaceConfig]{}.Get().GetIntWithDefault(ConfigPathPaginationMaxPageSize, DefaultPaginationMaxPageSize)
+	defaultPageSize := Config[InterfaceConfig]{}.Get().GetIntWithDefault(ConfigPathPaginationDefaultPageSize, DefaultPaginationPageSize)
+
+	// A page size that is not positive would limit the query to no row
+	if maxPageSize <= 0 {
+		maxPageSize = DefaultPaginationMaxPageSize
+	}
+	if defaultPageSize <= 0 {
+		defaultPageSize = DefaultPaginationPageSize
+	}
+
+	page_size, _ := strconv.Atoi(value)
+	if page_size <= 0 {
+		page_size = defaultPageSize
+	}
+	if page_size > maxPageSize {
+		page_size = maxPageSize
+	}
+	return page_size
+}
+
+// Keyset pagination, the cursor is read from the FieldNamePaginationCursor query parameter
+// At least one ordering column is required, the last column should be unique (usually the primary key)
+// The columns cannot be NULL, a nullable field, such as a pointer, needs the not null tag
+// The returned function applies the keyset conditions, fetches the data and returns the next and previous cursors
+// Example:
+// f := d.LibraryGorm{}.PaginateCursor(c.Request, d.CursorColumn{Name: "created_at", Desc: true}, d.CursorColumn{Name: "id", Desc: true})
+// page_size, next, prev, err := f(query, &data)
+func (l LibraryGorm) PaginateCursor(r *http.Request, columns ...CursorColumn) func(db *gorm.DB, data_list_pointer interface{}) (page_size int, next_cursor, prev_cursor string, err error) {
+	return func(db *gorm.DB, data_list_pointer interface{}) (page_size int, next_cursor, prev_cursor string, err error) {
+		if len(columns) == 0 {
+			return 0, "", "", ErrCursorNoColumns
+		}
+		if err = checkCursorColumns(db, columns, data_list_pointer); err != nil {
+			return 0, "", "", err
+		}
 
 		q := r.URL.Query()
+		page_size = l.pageSize(q.Get(FieldNamePaginationPageSize))
 
-		page, _ = strconv.Atoi(q.Get(FieldNamePaginationPage))
-		if page <= 0 {
-			page = 1
+		var c cursor
+		if raw := q.Get(FieldNamePaginationCursor); raw != "" {
+			c, err = decodeCursor(raw, len(columns))
+			if err != nil {
+				return page_size, "", "", err
+			}
 		}
 
-		page_size, _ = strconv.Atoi(q.Get(FieldNamePaginationPageSize))
-		switch {
-		case page_size > 100:
-			page_size = 100
-		case page_size <= 0:
-			page_size = 10
+		result := c.scope(columns)(db).Limit(page_size + 1).Find(data_list_pointer)
+		if result.Error != nil {
+			return page_size, "", "", result.Error
 		}
 
-		offset := (page - 1) * page_size
-		db.Offset(offset).Limit(page_size)
-		return page, page_size
+		return c.paginate(result, columns, data_list_pointer, page_size)
 	}
 }
 
+// Deprecated: Use LibraryGorm.Seed or Migrator instead, they record what was applied in the database rather than a config flag
 // Insert initialization data
 func (l LibraryGorm) InsertInitializationData(list ...interface{}) (initialized bool, err error) {
 	b := Config[InterfaceConfig]{}.Get().GetBool(ConfigPathInsertInitializationData)
@@ -147,22 +288,7 @@ func (l LibraryGorm) InsertInitializationData(list ...interface{}) (initialized
 // https://gorm.io/docs/scopes.html#Pagination
 func (l LibraryGorm) Paginate(r *http.Request) func(db *gorm.DB) *gorm.DB {
 	return func(db *gorm.DB) *gorm.DB {
-
-		q := r.URL.Query()
-
-		page, _ := strconv.Atoi(q.Get(FieldNamePaginationPage))
-		if page <= 0 {
-			page = 1
-		}
-
-		pageSize, _ := strconv.Atoi(q.Get(FieldNamePaginationPageSize))
-		switch {
-		case pageSize > 100:
-			pageSize = 100
-		case pageSize <= 0:
-			pageSize = 10
-		}
-
+		page, pageSize := l.pageAndPageSize(r.URL.Query())
 		offset := (page - 1) * pageSize
 		return db.Offset(offset).Limit(pageSize)
 	}
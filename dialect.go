@@ -0,0 +1,61 @@
+package d
+
+import (
+	"errors"
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+// Database dialect interface, implement at least the following methods to register a dialect for LibraryGorm
+type InterfaceDialect interface {
+	Dsn(host, name, user, password string) string // Build the DSN from the database.* config keys
+	Open(dsn string) gorm.Dialector
+	GenerateFuzzyQueries(fields map[string]string) (whereClause string, args []interface{}, err error)
+}
+
+// Optional dialect interface, implement it to support CountModeEstimated
+type InterfaceDialectEstimateCount interface {
+	EstimateCount(tx *gorm.DB, data_list_pointer interface{}) (int64, error)
+}
+
+const (
+	DatabaseDriverMySQL      = "mysql"
+	DatabaseDriverPostgreSQL = "postgres"
+	DatabaseDriverSQLite     = "sqlite"
+	DatabaseDriverSQLServer  = "sqlserver"
+)
+
+var (
+	ErrDatabaseDialectNotFound = errors.New("database dialect is not registered")
+)
+
+var (
+	// The key is the driver name, the same as gorm.Dialector.Name()
+	dialects = map[string]InterfaceDialect{
+		DatabaseDriverMySQL:      MySQL{},
+		DatabaseDriverPostgreSQL: PostgreSQL{},
+		DatabaseDriverSQLite:     SQLite{},
+		DatabaseDriverSQLServer:  SQLServer{},
+	}
+	dialectsMutex sync.RWMutex
+)
+
+// Register a database dialect, an existing dialect with the same name is replaced
+// Example: d.RegisterDatabaseDialect("clickhouse", ClickHouse{})
+func RegisterDatabaseDialect(name string, dialect InterfaceDialect) {
+	dialectsMutex.Lock()
+	defer dialectsMutex.Unlock()
+	dialects[name] = dialect
+}
+
+// Get the registered database dialect by driver name
+func GetDatabaseDialect(name string) (InterfaceDialect, error) {
+	dialectsMutex.RLock()
+	defer dialectsMutex.RUnlock()
+	dialect, ok := dialects[name]
+	if !ok {
+		return nil, ErrDatabaseDialectNotFound
+	}
+	return dialect, nil
+}
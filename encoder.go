@@ -0,0 +1,350 @@
+package d
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/ugorji/go/codec"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// Encoder of the API responses, it is chosen by the Accept header of the request
+type InterfaceApiEncoder interface {
+	ContentType() string                        // The Content-Type header of the response
+	Encode(w io.Writer, data interface{}) error // Encode the response, data is usually returned by InterfaceApi.Success, Error or Pagination
+}
+
+const (
+	MIMEApplicationJSON     = "application/json"
+	MIMEApplicationXML      = "application/xml"
+	MIMETextXML             = "text/xml"
+	MIMEApplicationMsgPack  = "application/msgpack"
+	MIMEApplicationXMsgPack = "application/x-msgpack"
+	MIMEApplicationProtobuf = "application/x-protobuf"
+)
+
+const (
+	ConfigPathApiDefaultContentType = "api.default_content_type" // The encoder used when the Accept header is empty, */* or not supported
+)
+
+var (
+	DefaultApiContentType = MIMEApplicationJSON
+)
+
+var (
+	ErrApiEncoderNotFound = errors.New("api encoder not found")
+)
+
+var (
+	apiEncodersMutex sync.RWMutex
+	apiEncoders      = map[string]InterfaceApiEncoder{
+		MIMEApplicationJSON:     ApiEncoderJSON{},
+		MIMEApplicationXML:      ApiEncoderXML{},
+		MIMETextXML:             ApiEncoderXML{},
+		MIMEApplicationMsgPack:  ApiEncoderMsgPack{},
+		MIMEApplicationXMsgPack: ApiEncoderMsgPack{},
+		MIMEApplicationProtobuf: ApiEncoderProtobuf{},
+	}
+)
+
+// Register an API encoder for a MIME type, an existing encoder of the MIME type is replaced
+// Example: d.RegisterApiEncoder("application/yaml", YAMLEncoder{})
+func RegisterApiEncoder(mime_type string, encoder InterfaceApiEncoder) {
+	apiEncodersMutex.Lock()
+	defer apiEncodersMutex.Unlock()
+	apiEncoders[strings.ToLower(mime_type)] = encoder
+}
+
+// Get the API encoder of a MIME type
+func GetApiEncoder(mime_type string) (InterfaceApiEncoder, error) {
+	apiEncodersMutex.RLock()
+	defer apiEncodersMutex.RUnlock()
+	if e, ok := apiEncoders[strings.ToLower(mime_type)]; ok {
+		return e, nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrApiEncoderNotFound, mime_type)
+}
+
+// Choose the API encoder by the Accept header, the media ranges are tried by their quality
+// The api.default_content_type config is used if the header is empty, */* or no media range is supported
+func NegotiateApiEncoder(accept string) InterfaceApiEncoder {
+	defaultType := Config[InterfaceConfig]{}.Get().GetStringWithDefault(ConfigPathApiDefaultContentType, DefaultApiContentType)
+	defaultEncoder, err := GetApiEncoder(defaultType)
+	if err != nil {
+		defaultEncoder = ApiEncoderJSON{}
+	}
+
+	for _, v := range parseAccept(accept) {
+		switch {
+		case v == "*/*":
+			return defaultEncoder
+		case strings.HasSuffix(v, "/*"):
+			// Such as application/*, the default is preferred if it matches
+			if strings.HasPrefix(defaultType, strings.TrimSuffix(v, "*")) {
+				return defaultEncoder
+			}
+			if e, ok := apiEncoderWithPrefix(strings.TrimSuffix(v, "*")); ok {
+				return e
+			}
+		default:
+			if e, err := GetApiEncoder(v); err == nil {
+				return e
+			}
+		}
+	}
+	return defaultEncoder
+}
+
+// The first encoder in order whose MIME type has the prefix
+func apiEncoderWithPrefix(prefix string) (InterfaceApiEncoder, bool) {
+	apiEncodersMutex.RLock()
+	defer apiEncodersMutex.RUnlock()
+	types := make([]string, 0, len(apiEncoders))
+	for k := range apiEncoders {
+		if strings.HasPrefix(k, prefix) {
+			types = append(types, k)
+		}
+	}
+	if len(types) == 0 {
+		return nil, false
+	}
+	sort.Strings(types)
+	return apiEncoders[types[0]], true
+}
+
+// The media ranges of the Accept header ordered by their quality, the ranges with q=0 are not acceptable
+func parseAccept(accept string) []string {
+	type mediaRange struct {
+		mime string
+		q    float64
+	}
+	var ranges []mediaRange
+	for _, v := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(v))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if s, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(s, 64); err != nil {
+				continue
+			}
+		}
+		if q > 0 {
+			ranges = append(ranges, mediaRange{mime: mediaType, q: q})
+		}
+	}
+	sort.SliceStable(ranges, func(i, j int) bool {
+		return ranges[i].q > ranges[j].q
+	})
+
+	list := make([]string, len(ranges))
+	for i, v := range ranges {
+		list[i] = v.mime
+	}
+	return list
+}
+
+// JSON encoder, the same output as gin.Context.JSON
+type ApiEncoderJSON struct{}
+
+func (e ApiEncoderJSON) ContentType() string {
+	return "application/json; charset=utf-8"
+}
+
+func (e ApiEncoderJSON) Encode(w io.Writer, data interface{}) error {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(b)
+	return err
+}
+
+// XML encoder, the response is the root element, the elements of an array are item elements
+// The names of the elements are the JSON names, so the api.field config is applied as well
+// A JSON name that is not a valid element name, such as "1" or "user name", is encoded as <entry key="1">
+type ApiEncoderXML struct{}
+
+func (e ApiEncoderXML) ContentType() string {
+	return "application/xml; charset=utf-8"
+}
+
+func (e ApiEncoderXML) Encode(w io.Writer, data interface{}) error {
+	v, err := toJSONValue(data)
+	if err != nil {
+		return err
+	}
+	if _, err = io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	if err = e.encodeElement(enc, "response", v); err != nil {
+		return err
+	}
+	return enc.Flush()
+}
+
+func (e ApiEncoderXML) encodeElement(enc *xml.Encoder, name string, value interface{}) error {
+	start := xml.StartElement{Name: xml.Name{Local: name}}
+	if !isXMLName(name) {
+		start = xml.StartElement{Name: xml.Name{Local: "entry"}, Attr: []xml.Attr{{Name: xml.Name{Local: "key"}, Value: name}}}
+	}
+	if err := enc.EncodeToken(start); err != nil {
+		return err
+	}
+	switch v := value.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if err := e.encodeElement(enc, k, v[k]); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		for _, item := range v {
+			if err := e.encodeElement(enc, "item", item); err != nil {
+				return err
+			}
+		}
+	case nil:
+	default:
+		if err := enc.EncodeToken(xml.CharData(fmt.Sprint(v))); err != nil {
+			return err
+		}
+	}
+	return enc.EncodeToken(start.End())
+}
+
+// Whether the name can be used as an element name, the colon is excluded as it is the namespace separator
+func isXMLName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i, r := range name {
+		switch {
+		case unicode.IsLetter(r) || r == '_':
+		case i > 0 && (unicode.IsDigit(r) || r == '-' || r == '.'):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// MessagePack encoder, the keys are the JSON names, so the api.field config is applied as well
+type ApiEncoderMsgPack struct{}
+
+func (e ApiEncoderMsgPack) ContentType() string {
+	return MIMEApplicationMsgPack
+}
+
+func (e ApiEncoderMsgPack) Encode(w io.Writer, data interface{}) error {
+	v, err := toJSONValue(data)
+	if err != nil {
+		return err
+	}
+	// Use the new spec of MessagePack, such as the str8 and bin formats
+	mh := codec.MsgpackHandle{WriteExt: true}
+	return codec.NewEncoder(w, &mh).Encode(v)
+}
+
+// Protobuf encoder, a proto.Message is encoded as it is, other data is encoded as google.protobuf.Struct
+// The keys of the Struct are the JSON names, so the api.field config is applied as well
+type ApiEncoderProtobuf struct{}
+
+func (e ApiEncoderProtobuf) ContentType() string {
+	return MIMEApplicationProtobuf
+}
+
+func (e ApiEncoderProtobuf) Encode(w io.Writer, data interface{}) error {
+	m, ok := data.(proto.Message)
+	if !ok {
+		v, err := toJSONValue(data)
+		if err != nil {
+			return err
+		}
+		value, err := structpb.NewValue(v)
+		if err != nil {
+			return err
+		}
+		m = value
+		if s := value.GetStructValue(); s != nil {
+			m = s
+		}
+	}
+	b, err := proto.Marshal(m)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(b)
+	return err
+}
+
+// Convert data to the value decoded from its JSON, so every encoder uses the JSON names
+// The integers are kept as int64, the other numbers are float64
+func toJSONValue(data interface{}) (interface{}, error) {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(strings.NewReader(string(b)))
+	dec.UseNumber()
+	var v interface{}
+	if err = dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	return fromJSONNumber(v), nil
+}
+
+func fromJSONNumber(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for k := range v {
+			v[k] = fromJSONNumber(v[k])
+		}
+	case []interface{}:
+		for i := range v {
+			v[i] = fromJSONNumber(v[i])
+		}
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		f, _ := v.Float64()
+		return f
+	}
+	return value
+}
+
+// Render of gin, encode data by the encoder
+type apiRender struct {
+	encoder InterfaceApiEncoder
+	data    interface{}
+}
+
+func (r apiRender) Render(w http.ResponseWriter) error {
+	r.WriteContentType(w)
+	return r.encoder.Encode(w, r.data)
+}
+
+func (r apiRender) WriteContentType(w http.ResponseWriter) {
+	if header := w.Header(); len(header["Content-Type"]) == 0 {
+		header["Content-Type"] = []string{r.encoder.ContentType()}
+	}
+}
@@ -0,0 +1,279 @@
+package d
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	ConfigPathEventBufferSize        = "event.buffer_size"        // The events kept for replaying, used when EventBroker.BufferSize is 0
+	ConfigPathEventHeartbeatInterval = "event.heartbeat_interval" // Seconds, the interval of the heartbeat comments of Server-Sent Events, 0 or less disables them
+	ConfigPathEventLongPollTimeout   = "event.long_poll_timeout"  // Seconds, how long a long-poll request waits for an event
+)
+
+var (
+	FieldNameEventLastID = "last_event_id" // Query parameter of the last received event, for the clients that cannot set the Last-Event-ID header
+
+	DefaultEventBufferSize        = 100
+	DefaultEventHeartbeatInterval = 15
+	DefaultEventLongPollTimeout   = 25
+)
+
+// The events a subscriber can fall behind before it is dropped
+const eventSubscriberBufferSize = 64
+
+var (
+	ErrEventBrokerClosed = errors.New("event broker is closed")
+)
+
+// Event of an EventBroker
+type Event struct {
+	ID   string      `json:"id"`
+	Name string      `json:"event"` // The type of the event, such as order.created, empty means message
+	Data interface{} `json:"data"`
+}
+
+// Publishes events to the Server-Sent Events and long-poll clients, the zero value is ready to use
+// The recent events are kept in a bounded buffer, so a reconnecting client receives the events it missed
+// Example:
+//
+//	var orders d.EventBroker
+//	router.GET("/orders/events", func(c *gin.Context) { d.Gin{}.SSE(c, api, &orders) })
+//	router.GET("/orders/poll", func(c *gin.Context) { d.Gin{}.LongPoll(c, api, &orders) })
+//	orders.Publish("order.created", order)
+type EventBroker struct {
+	BufferSize int // The events kept for replaying, if 0, the event.buffer_size config is used
+
+	mutex       sync.Mutex
+	lastID      uint64
+	events      []Event
+	subscribers map[chan Event]struct{}
+	closed      bool
+}
+
+// Publish an event to all subscribers, returns the event with its ID
+func (b *EventBroker) Publish(name string, data interface{}) Event {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.lastID++
+	e := Event{ID: strconv.FormatUint(b.lastID, 10), Name: name, Data: data}
+
+	size := b.BufferSize
+	if size <= 0 {
+		size = Config[InterfaceConfig]{}.Get().GetIntWithDefault(ConfigPathEventBufferSize, DefaultEventBufferSize)
+	}
+	b.events = append(b.events, e)
+	if len(b.events) > size {
+		b.events = slices.Delete(b.events, 0, len(b.events)-size)
+	}
+
+	for ch := range b.subscribers {
+		select {
+		case ch <- e:
+		default:
+			// The subscriber is too slow, it is dropped and replays the missed events when it reconnects
+			delete(b.subscribers, ch)
+			close(ch)
+		}
+	}
+	return e
+}
+
+// Close the broker, the connected clients are disconnected and publishing is still possible but nobody receives the events
+func (b *EventBroker) Close() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.closed = true
+	for ch := range b.subscribers {
+		close(ch)
+	}
+	b.subscribers = nil
+}
+
+// Subscribe to the events after last_id, returns the buffered events after it and the channel of the next events
+// If last_id is not in the buffer, such as it is too old or from before a restart, all buffered events are returned
+// If last_id is empty, no buffered event is returned
+func (b *EventBroker) subscribe(last_id string) ([]Event, chan Event, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.closed {
+		return nil, nil, ErrEventBrokerClosed
+	}
+
+	var replay []Event
+	if last_id != "" {
+		i := slices.IndexFunc(b.events, func(e Event) bool { return e.ID == last_id })
+		replay = slices.Clone(b.events[i+1:])
+	}
+
+	ch := make(chan Event, eventSubscriberBufferSize)
+	if b.subscribers == nil {
+		b.subscribers = make(map[chan Event]struct{})
+	}
+	b.subscribers[ch] = struct{}{}
+	return replay, ch, nil
+}
+
+func (b *EventBroker) unsubscribe(ch chan Event) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if _, ok := b.subscribers[ch]; ok {
+		delete(b.subscribers, ch)
+		close(ch)
+	}
+}
+
+// The last event ID of the request, the Last-Event-ID header or the last_event_id query parameter
+func lastEventID(c *gin.Context) string {
+	if id := c.GetHeader("Last-Event-ID"); id != "" {
+		return id
+	}
+	return c.Query(FieldNameEventLastID)
+}
+
+// Send the events of the broker as Server-Sent Events until the client disconnects
+// The data of every event is the success response of the api, a heartbeat comment is sent every event.heartbeat_interval seconds
+// A reconnecting client receives the events after its Last-Event-ID first
+// Example: router.GET("/events", func(c *gin.Context) { d.Gin{}.SSE(c, api, &broker) })
+func (g Gin) SSE(c *gin.Context, a InterfaceApi, broker *EventBroker) error {
+	// If gin.Context is nil
+	if c == nil {
+		return errors.New("gin.Context is nil")
+	}
+	if _, ok := a.(InterfaceApiData); !ok {
+		return ErrResourceApiNotSupported
+	}
+
+	replay, ch, err := broker.subscribe(lastEventID(c))
+	if err != nil {
+		return err
+	}
+	defer broker.unsubscribe(ch)
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	// Nginx buffers the responses of a proxied server by default
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	c.Writer.WriteHeaderNow()
+	c.Writer.Flush()
+
+	for _, e := range replay {
+		if err = g.writeSSE(c, a, e); err != nil {
+			return err
+		}
+	}
+
+	// A nil channel never receives, so no heartbeat is sent if they are disabled
+	var heartbeat <-chan time.Time
+	interval := Config[InterfaceConfig]{}.Get().GetIntWithDefault(ConfigPathEventHeartbeatInterval, DefaultEventHeartbeatInterval)
+	if interval > 0 {
+		ticker := time.NewTicker(time.Duration(interval) * time.Second)
+		defer ticker.Stop()
+		heartbeat = ticker.C
+	}
+
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return nil
+		case e, ok := <-ch:
+			if !ok {
+				// Dropped by the broker, the client reconnects with its Last-Event-ID
+				return nil
+			}
+			if err = g.writeSSE(c, a, e); err != nil {
+				return err
+			}
+		case <-heartbeat:
+			if _, err = c.Writer.WriteString(": heartbeat\n\n"); err != nil {
+				return err
+			}
+			c.Writer.Flush()
+		}
+	}
+}
+
+// Write an event, the api is chosen for every event, so the metadata of the envelope is up to date
+func (g Gin) writeSSE(c *gin.Context, a InterfaceApi, e Event) error {
+	a = g.forRequest(c, a.(InterfaceApiData).WithData(e.Data))
+	b, err := json.Marshal(a.Success())
+	if err != nil {
+		return err
+	}
+	if e.Name != "" {
+		if _, err = fmt.Fprintf(c.Writer, "id: %s\nevent: %s\ndata: %s\n\n", e.ID, e.Name, b); err != nil {
+			return err
+		}
+	} else if _, err = fmt.Fprintf(c.Writer, "id: %s\ndata: %s\n\n", e.ID, b); err != nil {
+		return err
+	}
+	c.Writer.Flush()
+	return nil
+}
+
+// Long-poll fallback of SSE, for the clients behind proxies that do not support Server-Sent Events
+// Responds with the events after the last_event_id query parameter as soon as there are any, or an empty list after event.long_poll_timeout seconds
+// The data of the success response of the api is the list of events, the client sends the ID of the last event with the next request
+// Example: router.GET("/events/poll", func(c *gin.Context) { d.Gin{}.LongPoll(c, api, &broker) })
+func (g Gin) LongPoll(c *gin.Context, a InterfaceApi, broker *EventBroker) error {
+	// If gin.Context is nil
+	if c == nil {
+		return errors.New("gin.Context is nil")
+	}
+	ad, ok := a.(InterfaceApiData)
+	if !ok {
+		return ErrResourceApiNotSupported
+	}
+
+	events, ch, err := broker.subscribe(lastEventID(c))
+	if err != nil {
+		return err
+	}
+	defer broker.unsubscribe(ch)
+
+	if len(events) == 0 {
+		timeout := Config[InterfaceConfig]{}.Get().GetIntWithDefault(ConfigPathEventLongPollTimeout, DefaultEventLongPollTimeout)
+		if timeout <= 0 {
+			timeout = DefaultEventLongPollTimeout
+		}
+		timer := time.NewTimer(time.Duration(timeout) * time.Second)
+		defer timer.Stop()
+
+		select {
+		case <-c.Request.Context().Done():
+			return nil
+		case <-timer.C:
+		case e, ok := <-ch:
+			if ok {
+				events = append(events, e)
+			}
+		}
+
+		// The events published at the same time are responded together
+		for len(ch) > 0 {
+			e, ok := <-ch
+			if !ok {
+				break
+			}
+			events = append(events, e)
+		}
+	}
+
+	if events == nil {
+		events = []Event{}
+	}
+	c.Header("Cache-Control", "no-cache")
+	g.Success(c, ad.WithData(events))
+	return nil
+}
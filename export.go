@@ -0,0 +1,464 @@
+package d
+
+import (
+	"archive/zip"
+	"database/sql/driver"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// File format of the list export
+type ExportFormat string
+
+const (
+	ExportFormatCSV  ExportFormat = "csv"
+	ExportFormatXLSX ExportFormat = "xlsx"
+)
+
+const (
+	ConfigPathExportBatchSize = "export.batch_size" // The rows fetched by one query of the export
+	ConfigPathExportMaxRows   = "export.max_rows"   // 0 means the export is not limited
+)
+
+var (
+	FieldNameExportFormat = "format" // Query parameter of the export format, csv or xlsx
+
+	DefaultExportBatchSize = 1000
+	DefaultExportMaxRows   = 0
+)
+
+var (
+	ErrExportFormatNotSupported = errors.New("export format is not supported")
+	ErrExportNotSlice           = errors.New("export requires a pointer to a slice of structs")
+)
+
+// The content type of the format
+func (f ExportFormat) ContentType() string {
+	switch f {
+	case ExportFormatXLSX:
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	}
+	return "text/csv; charset=utf-8"
+}
+
+// Read the export format from the query, default is csv
+func ParseExportFormat(values url.Values) (ExportFormat, error) {
+	switch f := ExportFormat(strings.ToLower(values.Get(FieldNameExportFormat))); f {
+	case "":
+		return ExportFormatCSV, nil
+	case ExportFormatCSV, ExportFormatXLSX:
+		return f, nil
+	default:
+		return "", fmt.Errorf("%w: %s", ErrExportFormatNotSupported, f)
+	}
+}
+
+// Export the list with fuzzy, filter and sort query to w, the pagination query is ignored
+// The rows are fetched in batches of export.batch_size into data_list_pointer, a pointer to a slice of structs, w is flushed after every batch if it is a http.Flusher
+// The headers are the JSON names of the fields renamed by the api.field and api.casing config, the export tag overrides them, export:"-" skips a field
+// Example:
+// err := d.LibraryGorm{}.Export(w, query, r.URL.Query(), d.ListQuery{FuzzyFields: []string{"name"}}, &[]User{}, d.ExportFormatXLSX)
+func (l LibraryGorm) Export(w io.Writer, query *gorm.DB, values url.Values, q ListQuery, data_list_pointer interface{}, format ExportFormat) error {
+	list := reflect.ValueOf(data_list_pointer)
+	if list.Kind() != reflect.Pointer || list.Elem().Kind() != reflect.Slice {
+		return ErrExportNotSlice
+	}
+	elemType := list.Elem().Type().Elem()
+	for elemType.Kind() == reflect.Pointer {
+		elemType = elemType.Elem()
+	}
+	if elemType.Kind() != reflect.Struct {
+		return ErrExportNotSlice
+	}
+
+	var ew exportWriter
+	switch format {
+	case ExportFormatCSV:
+		ew = newCSVExportWriter(w)
+	case ExportFormatXLSX:
+		ew = newXLSXExportWriter(w)
+	default:
+		return fmt.Errorf("%w: %s", ErrExportFormatNotSupported, format)
+	}
+
+	tx, _, err := l.ApplyListQuery(query, values, q)
+	if err != nil {
+		return err
+	}
+
+	columns := exportColumns(elemType, newApiFieldRenamer())
+	headers := make([]string, len(columns))
+	for i, v := range columns {
+		headers[i] = v.header
+	}
+
+	batchSize := Config[InterfaceConfig]{}.Get().GetIntWithDefault(ConfigPathExportBatchSize, DefaultExportBatchSize)
+	maxRows := Config[InterfaceConfig]{}.Get().GetIntWithDefault(ConfigPathExportMaxRows, DefaultExportMaxRows)
+	if batchSize <= 0 {
+		batchSize = DefaultExportBatchSize
+	}
+
+	// The batches keep the sort of the query and the preloads
+	// A batch starts after the last row of the previous batch, the rows are not skipped or repeated and the batches stay fast
+	tx, order, keyset := exportOrder(tx, data_list_pointer)
+	var after cursor
+	for offset := 0; maxRows <= 0 || offset < maxRows; offset += batchSize {
+		limit := batchSize
+		if maxRows > 0 && offset+limit > maxRows {
+			limit = maxRows - offset
+		}
+		list.Elem().SetLen(0)
+		batch := tx.Session(&gorm.Session{})
+		if !keyset {
+			batch = batch.Offset(offset)
+		} else if after.isSet() {
+			condition, err := after.condition(order)
+			if err != nil {
+				return err
+			}
+			batch = batch.Where(condition)
+		}
+		result := batch.Limit(limit).Find(data_list_pointer)
+		if err = result.Error; err != nil {
+			return err
+		}
+		// Nothing is written until the first batch is fetched, so an error of the query can still be responded
+		if offset == 0 {
+			if err = ew.writeHeader(headers); err != nil {
+				return err
+			}
+		}
+
+		rows := list.Elem()
+		for i := 0; i < rows.Len(); i++ {
+			row := reflect.Indirect(rows.Index(i))
+			cells := make([]interface{}, len(columns))
+			for j, v := range columns {
+				cells[j] = exportValue(row, v.index)
+			}
+			if err = ew.writeRow(cells); err != nil {
+				return err
+			}
+		}
+		if err = ew.flush(); err != nil {
+			return err
+		}
+		if f, ok := w.(http.Flusher); ok {
+			f.Flush()
+		}
+		if rows.Len() < limit {
+			break
+		}
+		if keyset {
+			// Such as a NULL value, the next batches continue by offset in the same order
+			if after, err = cursorAt(result, order, rows.Index(rows.Len()-1)); err != nil {
+				keyset = false
+			}
+		}
+	}
+	return ew.close()
+}
+
+// Make the order of the query stable by the primary key, returns the ordering columns if the batches can continue after the last row
+// If the order cannot be read, such as an order of raw SQL, or the model has no primary key, the batches are fetched by offset
+func exportOrder(tx *gorm.DB, data_list_pointer interface{}) (*gorm.DB, []CursorColumn, bool) {
+	// The query is built without running it, so the order includes the order of the scopes
+	dryRun := tx.Session(&gorm.Session{DryRun: true}).Find(data_list_pointer)
+	if dryRun.Error != nil || dryRun.Statement.Schema == nil || dryRun.Statement.Schema.PrioritizedPrimaryField == nil {
+		return tx, nil, false
+	}
+	pk := dryRun.Statement.Schema.PrioritizedPrimaryField.DBName
+
+	var columns []CursorColumn
+	keyset, ordered := true, false
+	if c, ok := dryRun.Statement.Clauses["ORDER BY"]; ok {
+		if orderBy, ok := c.Expression.(clause.OrderBy); ok && orderBy.Expression == nil {
+			for _, v := range orderBy.Columns {
+				if v.Column.Raw || v.Column.Name == "" {
+					keyset = false
+				}
+				columns = append(columns, CursorColumn{Name: v.Column.Name, Desc: v.Desc})
+				ordered = ordered || v.Column.Name == pk
+			}
+		} else {
+			keyset = false
+		}
+	}
+	if !ordered {
+		// A scope, so the primary key is ordered after the order of the scopes of the query
+		tx = tx.Scopes(func(db *gorm.DB) *gorm.DB {
+			return db.Order(clause.OrderByColumn{Column: clause.Column{Name: pk}})
+		})
+		columns = append(columns, CursorColumn{Name: pk})
+	}
+	if keyset && checkCursorColumns(tx, columns, data_list_pointer) != nil {
+		keyset = false
+	}
+	return tx, columns, keyset
+}
+
+// Column of the export
+type exportColumn struct {
+	index  []int // Index of the field, see reflect.Value.FieldByIndex
+	header string
+}
+
+// The exported fields of the struct, the fields of an embedded struct are promoted like JSON
+func exportColumns(t reflect.Type, r fieldRenamer) []exportColumn {
+	var columns []exportColumn
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		jsonName := strings.Split(f.Tag.Get("json"), ",")[0]
+		exportName := f.Tag.Get("export")
+		if jsonName == "-" || exportName == "-" {
+			continue
+		}
+
+		ft := f.Type
+		for ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		if f.Anonymous && jsonName == "" && ft.Kind() == reflect.Struct {
+			for _, v := range exportColumns(ft, r) {
+				columns = append(columns, exportColumn{index: append([]int{i}, v.index...), header: v.header})
+			}
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+
+		header := exportName
+		if header == "" {
+			header = jsonName
+			if header == "" {
+				header = f.Name
+			}
+			if name, ok := r.nameOf("", header); ok {
+				header = name
+			}
+		}
+		columns = append(columns, exportColumn{index: []int{i}, header: header})
+	}
+	return columns
+}
+
+// The value of a cell, numbers and booleans are kept, nested values are JSON, others are strings
+func exportValue(row reflect.Value, index []int) interface{} {
+	v, err := row.FieldByIndexErr(index)
+	if err != nil {
+		// A nil embedded pointer
+		return ""
+	}
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return ""
+		}
+		v = v.Elem()
+	}
+
+	value := v.Interface()
+	// Such as gorm.DeletedAt and sql.NullString
+	if valuer, ok := value.(driver.Valuer); ok {
+		if value, err = valuer.Value(); err != nil || value == nil {
+			return ""
+		}
+		v = reflect.ValueOf(value)
+	}
+
+	switch x := value.(type) {
+	case time.Time:
+		if x.IsZero() {
+			return ""
+		}
+		return x.Format(time.RFC3339)
+	case []byte:
+		return string(x)
+	case fmt.Stringer:
+		return x.String()
+	}
+
+	// Named types, such as type Status int, are converted to the basic types
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return v.Uint()
+	case reflect.Float32, reflect.Float64:
+		return v.Float()
+	case reflect.Bool:
+		return v.Bool()
+	case reflect.String:
+		return v.String()
+	case reflect.Struct, reflect.Slice, reflect.Array, reflect.Map:
+		b, _ := json.Marshal(value)
+		return string(b)
+	}
+	return fmt.Sprint(value)
+}
+
+// Writer of an export format
+type exportWriter interface {
+	writeHeader(headers []string) error
+	writeRow(cells []interface{}) error
+	flush() error // Called after every batch
+	close() error
+}
+
+type csvExportWriter struct {
+	w   io.Writer
+	csv *csv.Writer
+}
+
+func newCSVExportWriter(w io.Writer) *csvExportWriter {
+	return &csvExportWriter{w: w, csv: csv.NewWriter(w)}
+}
+
+func (e *csvExportWriter) writeHeader(headers []string) error {
+	// The byte order mark makes spreadsheet applications read the file as UTF-8
+	if _, err := io.WriteString(e.w, "\ufeff"); err != nil {
+		return err
+	}
+	return e.csv.Write(headers)
+}
+
+func (e *csvExportWriter) writeRow(cells []interface{}) error {
+	record := make([]string, len(cells))
+	for i, v := range cells {
+		record[i] = fmt.Sprint(v)
+		// A text starting like a formula is run by spreadsheet applications, the quote makes it a text, numbers such as -1 are kept
+		if s, ok := v.(string); ok && s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+			record[i] = "'" + s
+		}
+	}
+	return e.csv.Write(record)
+}
+
+func (e *csvExportWriter) flush() error {
+	e.csv.Flush()
+	return e.csv.Error()
+}
+
+func (e *csvExportWriter) close() error {
+	return e.flush()
+}
+
+// XLSX writer, a workbook with one sheet, the cells are written as inline strings or numbers
+// The sheet is streamed, the zip is written without seeking
+type xlsxExportWriter struct {
+	zip   *zip.Writer
+	sheet io.Writer
+}
+
+const (
+	xlsxContentTypes = xml.Header + `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+		`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+		`<Default Extension="xml" ContentType="application/xml"/>` +
+		`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+		`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+		`</Types>`
+	xlsxRels = xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+		`</Relationships>`
+	xlsxWorkbook = xml.Header + `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+		`<sheets><sheet name="Sheet1" sheetId="1" r:id="rId1"/></sheets>` +
+		`</workbook>`
+	xlsxWorkbookRels = xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+		`</Relationships>`
+	xlsxSheetStart = xml.Header + `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`
+	xlsxSheetEnd   = `</sheetData></worksheet>`
+)
+
+func newXLSXExportWriter(w io.Writer) *xlsxExportWriter {
+	return &xlsxExportWriter{zip: zip.NewWriter(w)}
+}
+
+func (e *xlsxExportWriter) writeHeader(headers []string) error {
+	for _, v := range []struct{ name, content string }{
+		{"[Content_Types].xml", xlsxContentTypes},
+		{"_rels/.rels", xlsxRels},
+		{"xl/workbook.xml", xlsxWorkbook},
+		{"xl/_rels/workbook.xml.rels", xlsxWorkbookRels},
+	} {
+		f, err := e.zip.Create(v.name)
+		if err != nil {
+			return err
+		}
+		if _, err = io.WriteString(f, v.content); err != nil {
+			return err
+		}
+	}
+
+	sheet, err := e.zip.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return err
+	}
+	e.sheet = sheet
+	if _, err = io.WriteString(e.sheet, xlsxSheetStart); err != nil {
+		return err
+	}
+
+	cells := make([]interface{}, len(headers))
+	for i, v := range headers {
+		cells[i] = v
+	}
+	return e.writeRow(cells)
+}
+
+func (e *xlsxExportWriter) writeRow(cells []interface{}) error {
+	var b strings.Builder
+	b.WriteString("<row>")
+	for _, v := range cells {
+		// NaN and infinity are not numbers of a spreadsheet, they are written as text
+		if f, ok := v.(float64); ok && (math.IsNaN(f) || math.IsInf(f, 0)) {
+			v = strconv.FormatFloat(f, 'g', -1, 64)
+		}
+		switch x := v.(type) {
+		case int64, uint64:
+			fmt.Fprintf(&b, "<c><v>%d</v></c>", x)
+		case float64:
+			fmt.Fprintf(&b, "<c><v>%s</v></c>", strconv.FormatFloat(x, 'g', -1, 64))
+		case bool:
+			if x {
+				b.WriteString(`<c t="b"><v>1</v></c>`)
+			} else {
+				b.WriteString(`<c t="b"><v>0</v></c>`)
+			}
+		default:
+			b.WriteString(`<c t="inlineStr"><is><t xml:space="preserve">`)
+			if err := xml.EscapeText(&b, []byte(fmt.Sprint(x))); err != nil {
+				return err
+			}
+			b.WriteString(`</t></is></c>`)
+		}
+	}
+	b.WriteString("</row>")
+	_, err := io.WriteString(e.sheet, b.String())
+	return err
+}
+
+func (e *xlsxExportWriter) flush() error {
+	return e.zip.Flush()
+}
+
+func (e *xlsxExportWriter) close() error {
+	if _, err := io.WriteString(e.sheet, xlsxSheetEnd); err != nil {
+		return err
+	}
+	return e.zip.Close()
+}
@@ -0,0 +1,242 @@
+package d
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Renames the keys of JSON values by the api.field and api.casing config
+// A rule without a path, such as user_name: userName, renames the key at any level
+// A rule with a path, such as data.list[].user_name: userName, only renames the key at that path, [] stands for the elements of an array
+// The path is made of the keys before renaming, path rules take precedence over rules without a path, then the casing is applied
+// A new name is given to one key only, so the renaming can be reversed: if several rules give the same name, the first key in order gets it,
+// and a key whose casing gives the name of another rule keeps its name, the other keys fall back to the casing or keep their names
+type fieldRenamer struct {
+	keys      map[string]string // Rules without a path
+	paths     map[string]string // Rules with a path, by the full path of the key
+	keyNames  map[string]string // The key getting the new name of the rules without a path, by the new name
+	pathNames map[string]string // The key getting the new name of the rules with a path, by the path of the parent joined with the new name
+	casing    FieldCasing       // The casing of the keys without a rule, empty means they are not renamed
+	inverse   bool              // Whether the renamer maps the new names back to the keys, the paths are still made of the keys
+}
+
+// The renamer of the responses by the config, the rules with a path can be written as a dotted key or as nested keys
+// Viper reads the keys of the rules in lowercase
+func newApiFieldRenamer() fieldRenamer {
+	conf := Config[InterfaceConfig]{}.Get()
+	return newFieldRenamer(conf.GetStringMap(ConfigPathApiField), FieldCasing(conf.GetStringWithDefault(ConfigPathApiCasing, "")))
+}
+
+func newFieldRenamer(fieldMap map[string]interface{}, casing FieldCasing) fieldRenamer {
+	keys := make(map[string]string)
+	paths := make(map[string]string)
+	flattenFieldRules(fieldMap, "", func(k, name string) {
+		if strings.ContainsAny(k, ".[") {
+			paths[k] = name
+		} else {
+			keys[k] = name
+		}
+	})
+	return newFieldRenamerWithRules(keys, paths, casing, false)
+}
+
+// Read the rules of a field map, Viper splits the keys with a dot, such as data.list[].user_name, into nested maps
+// The path of a nested rule is rebuilt by joining the keys of the maps
+func flattenFieldRules(fieldMap map[string]interface{}, path string, fn func(key, name string)) {
+	for k, v := range fieldMap {
+		switch v := v.(type) {
+		case string:
+			if v != "" {
+				fn(joinFieldPath(path, k), v)
+			}
+		case map[string]interface{}:
+			flattenFieldRules(v, joinFieldPath(path, k), fn)
+		case map[interface{}]interface{}:
+			m := make(map[string]interface{}, len(v))
+			for mk, mv := range v {
+				if s, ok := mk.(string); ok {
+					m[s] = mv
+				}
+			}
+			flattenFieldRules(m, joinFieldPath(path, k), fn)
+		}
+	}
+}
+
+func newFieldRenamerWithRules(keys, paths map[string]string, casing FieldCasing, inverse bool) fieldRenamer {
+	r := fieldRenamer{keys: keys, paths: paths, keyNames: make(map[string]string, len(keys)), pathNames: make(map[string]string, len(paths)), casing: casing, inverse: inverse}
+	for k, v := range keys {
+		if old, ok := r.keyNames[v]; !ok || k < old {
+			r.keyNames[v] = k
+		}
+	}
+	for k, v := range paths {
+		parent, key := splitFieldPath(k)
+		p := joinFieldPath(parent, v)
+		if old, ok := r.pathNames[p]; !ok || key < old {
+			r.pathNames[p] = key
+		}
+	}
+	return r
+}
+
+// Returns the renamer applying the rules in the other direction, such as userName to user_name for the requests
+// If several keys have the same new name, the key getting the name is used, see fieldRenamer
+// With a casing, the keys without a rule are converted back to snake_case, the casing of the keys of the devtool library
+func (r fieldRenamer) reverse() fieldRenamer {
+	keys := make(map[string]string, len(r.keyNames))
+	for name, k := range r.keyNames {
+		keys[name] = k
+	}
+	// The path of the parent stays the same, only the last key is renamed
+	paths := make(map[string]string, len(r.pathNames))
+	for p, k := range r.pathNames {
+		paths[p] = k
+	}
+	var casing FieldCasing
+	if r.casing != "" {
+		casing = FieldCasingSnake
+	}
+	return newFieldRenamerWithRules(keys, paths, casing, !r.inverse)
+}
+
+// Whether there are no rules
+func (r fieldRenamer) empty() bool {
+	return len(r.keys) == 0 && len(r.paths) == 0 && r.casing == ""
+}
+
+// Returns a copy of value with the keys renamed, value is a decoded JSON value, value itself is not modified
+func (r fieldRenamer) rename(value interface{}) interface{} {
+	return r.renameAt(value, "")
+}
+
+func (r fieldRenamer) renameAt(value interface{}, path string) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		// The keys are visited in order, so that a renamed key overwrites an existing key deterministically
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		m := make(map[string]interface{}, len(v))
+		var renamed []string
+		for _, k := range keys {
+			if name, ok := r.nameOf(path, k); ok && name != k {
+				renamed = append(renamed, k)
+				continue
+			}
+			m[k] = r.renameAt(v[k], joinFieldPath(path, k))
+		}
+		for _, k := range renamed {
+			name, _ := r.nameOf(path, k)
+			m[name] = r.renameAt(v[k], r.childPath(path, k, name))
+		}
+		return m
+	case []interface{}:
+		list := make([]interface{}, len(v))
+		for i := range v {
+			list[i] = r.renameAt(v[i], path+"[]")
+		}
+		return list
+	}
+	return value
+}
+
+// The new name of the key at path
+func (r fieldRenamer) nameOf(path, key string) (string, bool) {
+	if name, ok := r.paths[joinFieldPath(path, key)]; ok && !r.claimed(path, key, name) {
+		return name, true
+	}
+	if name, ok := r.keys[key]; ok && !r.claimed(path, key, name) {
+		return name, true
+	}
+	if r.casing != "" {
+		if name := ConvertFieldCasing(key, r.casing); !r.claimed(path, key, name) {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// Whether a rule gives the name to another key at path
+func (r fieldRenamer) claimed(path, key, name string) bool {
+	if k, ok := r.pathNames[joinFieldPath(path, name)]; ok {
+		return k != key
+	}
+	if k, ok := r.keyNames[name]; ok && k != key {
+		// The rule of the other key does not apply if a rule with a path renames it at path
+		_, ok = r.paths[joinFieldPath(path, k)]
+		return !ok
+	}
+	return false
+}
+
+// The path of a renamed key, it is made of the key before renaming
+func (r fieldRenamer) childPath(path, key, name string) string {
+	if r.inverse {
+		return joinFieldPath(path, name)
+	}
+	return joinFieldPath(path, key)
+}
+
+// Rename the key of a form field, such as items[0][userName] or filter[userName][eq]
+// The numeric and empty brackets stand for the elements of an array
+func (r fieldRenamer) renameFormKey(key string) string {
+	name, rest, _ := strings.Cut(key, "[")
+	if name == "" {
+		return key
+	}
+
+	var b strings.Builder
+	path := ""
+	renameSegment := func(segment string) string {
+		if n, ok := r.nameOf(path, segment); ok {
+			path = r.childPath(path, segment, n)
+			return n
+		}
+		path = joinFieldPath(path, segment)
+		return segment
+	}
+	b.WriteString(renameSegment(name))
+
+	for len(rest) > 0 {
+		segment, after, ok := strings.Cut(rest, "]")
+		if !ok {
+			// Not a bracket, keep the rest as it is
+			b.WriteString("[" + rest)
+			break
+		}
+		b.WriteString("[")
+		if _, err := strconv.Atoi(segment); err == nil || segment == "" {
+			path += "[]"
+			b.WriteString(segment)
+		} else {
+			b.WriteString(renameSegment(segment))
+		}
+		b.WriteString("]")
+		rest, ok = strings.CutPrefix(after, "[")
+		if !ok {
+			b.WriteString(after)
+			break
+		}
+	}
+	return b.String()
+}
+
+// Split a path into the path of the parent and the last key
+func splitFieldPath(path string) (string, string) {
+	if i := strings.LastIndex(path, "."); i >= 0 {
+		return path[:i], path[i+1:]
+	}
+	return "", path
+}
+
+func joinFieldPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
@@ -0,0 +1,238 @@
+package d
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// Initialize the config by a config.yaml of content, through LibraryViper
+func initTestConfig(t *testing.T, content string) {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "config.yaml"), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	LibraryViper{AddConfigPath: dir, OnConfigChange: func(e fsnotify.Event) {}}.Init()
+}
+
+// Decode the JSON of a test case
+func decodeTestJSON(t *testing.T, s string) interface{} {
+	t.Helper()
+	var v interface{}
+	if err := json.Unmarshal([]byte(s), &v); err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestFieldRenamerRename(t *testing.T) {
+	tests := []struct {
+		name   string
+		rules  map[string]interface{}
+		casing FieldCasing
+		input  string
+		want   string
+	}{
+		{
+			name:  "scalar key",
+			rules: map[string]interface{}{"user_name": "userName"},
+			input: `{"user_name":"a","age":1}`,
+			want:  `{"userName":"a","age":1}`,
+		},
+		{
+			name:  "key of an object",
+			rules: map[string]interface{}{"data": "payload", "user_name": "userName"},
+			input: `{"data":{"user_name":"a","profile":{"user_name":"b"}}}`,
+			want:  `{"payload":{"userName":"a","profile":{"userName":"b"}}}`,
+		},
+		{
+			name:  "key of an array of objects",
+			rules: map[string]interface{}{"list": "items", "user_name": "userName"},
+			input: `{"list":[{"user_name":"a"},{"user_name":"b","tags":[{"user_name":"c"}]}]}`,
+			want:  `{"items":[{"userName":"a"},{"userName":"b","tags":[{"userName":"c"}]}]}`,
+		},
+		{
+			name:  "top level array",
+			rules: map[string]interface{}{"user_name": "userName"},
+			input: `[{"user_name":"a"},1,"user_name"]`,
+			want:  `[{"userName":"a"},1,"user_name"]`,
+		},
+		{
+			name:  "path rule",
+			rules: map[string]interface{}{"data.list[].user_name": "userName"},
+			input: `{"user_name":"a","data":{"user_name":"b","list":[{"user_name":"c"}]}}`,
+			want:  `{"user_name":"a","data":{"user_name":"b","list":[{"userName":"c"}]}}`,
+		},
+		{
+			name:  "path rule of a renamed parent",
+			rules: map[string]interface{}{"data": "payload", "list": "items", "data.list[].user_name": "userName"},
+			input: `{"data":{"list":[{"user_name":"c"}]}}`,
+			want:  `{"payload":{"items":[{"userName":"c"}]}}`,
+		},
+		{
+			name:  "path rule takes precedence",
+			rules: map[string]interface{}{"user_name": "userName", "data.user_name": "login"},
+			input: `{"user_name":"a","data":{"user_name":"b"}}`,
+			want:  `{"userName":"a","data":{"login":"b"}}`,
+		},
+		{
+			name:  "nested rules of viper",
+			rules: map[string]interface{}{"data": map[string]interface{}{"list[]": map[string]interface{}{"user_name": "userName"}}},
+			input: `{"data":{"list":[{"user_name":"c"}]}}`,
+			want:  `{"data":{"list":[{"userName":"c"}]}}`,
+		},
+		{
+			name:   "casing",
+			casing: FieldCasingCamel,
+			input:  `{"user_name":"a","data":{"list":[{"created_at":1}]}}`,
+			want:   `{"userName":"a","data":{"list":[{"createdAt":1}]}}`,
+		},
+		{
+			name:   "rule takes precedence over casing",
+			rules:  map[string]interface{}{"user_name": "login"},
+			casing: FieldCasingCamel,
+			input:  `{"user_name":"a","created_at":1}`,
+			want:   `{"login":"a","createdAt":1}`,
+		},
+		{
+			name:  "several keys with the same name",
+			rules: map[string]interface{}{"a": "x", "b": "x"},
+			input: `{"a":1,"b":2}`,
+			want:  `{"x":1,"b":2}`,
+		},
+		{
+			name:   "casing gives the name of a rule",
+			rules:  map[string]interface{}{"user_name": "userId"},
+			casing: FieldCasingCamel,
+			input:  `{"user_name":"a","user_id":1}`,
+			want:   `{"userId":"a","user_id":1}`,
+		},
+		{
+			name:  "swapped keys",
+			rules: map[string]interface{}{"id": "key", "key": "id"},
+			input: `{"id":1,"key":2}`,
+			want:  `{"key":1,"id":2}`,
+		},
+		{
+			name:  "empty rule",
+			rules: map[string]interface{}{"user_name": ""},
+			input: `{"user_name":"a"}`,
+			want:  `{"user_name":"a"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := decodeTestJSON(t, tt.input)
+			got := newFieldRenamer(tt.rules, tt.casing).rename(input)
+			if want := decodeTestJSON(t, tt.want); !reflect.DeepEqual(got, want) {
+				t.Errorf("rename() = %v, want %v", got, want)
+			}
+			// The input is not modified
+			if !reflect.DeepEqual(input, decodeTestJSON(t, tt.input)) {
+				t.Errorf("rename() modified the input to %v", input)
+			}
+		})
+	}
+}
+
+func TestFieldRenamerRenameFormKey(t *testing.T) {
+	r := newFieldRenamer(map[string]interface{}{"items": "list", "user_name": "userName", "filter.price": "cost"}, "")
+	tests := []struct {
+		key  string
+		want string
+	}{
+		{"user_name", "userName"},
+		{"items[0][user_name]", "list[0][userName]"},
+		{"items[][user_name]", "list[][userName]"},
+		{"filter[price][gte]", "filter[cost][gte]"},
+		{"filter[user_name", "filter[user_name"},
+		{"[user_name]", "[user_name]"},
+		{"age", "age"},
+	}
+	for _, tt := range tests {
+		if got := r.renameFormKey(tt.key); got != tt.want {
+			t.Errorf("renameFormKey(%q) = %q, want %q", tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestLibraryApiModifyApiFieldName(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		data   interface{}
+		want   string
+	}{
+		{
+			name: "nested objects and arrays of objects",
+			config: `
+api:
+  field:
+    data: payload
+    user_name: userName
+`,
+			data: library_api_response{Success: true, Data: map[string]interface{}{"list": []map[string]interface{}{{"user_name": "a"}}}},
+			want: `{"success":true,"code":0,"message":"","payload":{"list":[{"userName":"a"}]},"error":null}`,
+		},
+		{
+			name: "path rule with dots",
+			config: `
+api:
+  field:
+    "data.list[].user_name": userName
+`,
+			data: library_api_response{Data: map[string]interface{}{"user_name": "b", "list": []map[string]interface{}{{"user_name": "a"}}}},
+			want: `{"success":false,"code":0,"message":"","data":{"user_name":"b","list":[{"userName":"a"}]},"error":null}`,
+		},
+		{
+			name: "path rule with nested keys",
+			config: `
+api:
+  field:
+    data:
+      list[]:
+        user_name: userName
+`,
+			data: library_api_response{Data: map[string]interface{}{"user_name": "b", "list": []map[string]interface{}{{"user_name": "a"}}}},
+			want: `{"success":false,"code":0,"message":"","data":{"user_name":"b","list":[{"userName":"a"}]},"error":null}`,
+		},
+		{
+			name: "casing",
+			config: `
+api:
+  casing: pascal
+  field:
+    message: Msg
+`,
+			data: library_api_response{Data: map[string]interface{}{"user_name": "a"}},
+			want: `{"Success":false,"Code":0,"Msg":"","Data":{"UserName":"a"},"Error":null}`,
+		},
+		{
+			name:   "no rules",
+			config: `api: {}`,
+			data:   map[string]interface{}{"user_name": "a"},
+			want:   `{"user_name":"a"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			initTestConfig(t, tt.config)
+			got, err := LibraryApi{}.ModifyApiFieldName(tt.data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, err := json.Marshal(got)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(decodeTestJSON(t, string(b)), decodeTestJSON(t, tt.want)) {
+				t.Errorf("ModifyApiFieldName() = %s, want %s", b, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,184 @@
+package d
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Operator of the filter query, such as ?filter[price][gte]=10
+type FilterOperator string
+
+const (
+	FilterOperatorEq      FilterOperator = "eq"
+	FilterOperatorNe      FilterOperator = "ne"
+	FilterOperatorGt      FilterOperator = "gt"
+	FilterOperatorGte     FilterOperator = "gte"
+	FilterOperatorLt      FilterOperator = "lt"
+	FilterOperatorLte     FilterOperator = "lte"
+	FilterOperatorIn      FilterOperator = "in"      // Comma separated values, ?filter[status][in]=a,b
+	FilterOperatorBetween FilterOperator = "between" // Two comma separated values, ?filter[price][between]=10,20
+	FilterOperatorIsNull  FilterOperator = "is_null" // true or false, ?filter[deleted_at][is_null]=true
+	FilterOperatorPrefix  FilterOperator = "prefix"  // Starts with the value, LIKE wildcards are matched literally
+	FilterOperatorExact   FilterOperator = "exact"   // Case-sensitive equality
+)
+
+var (
+	FieldNameFilter = "filter"
+)
+
+var (
+	ErrFilterFieldNotAllowed    = errors.New("filter field is not allowed")
+	ErrFilterOperatorNotAllowed = errors.New("filter operator is not allowed")
+	ErrFilterInvalidValue       = errors.New("invalid filter value")
+)
+
+// Error of a single filter condition, use errors.Is to check the reason
+type FilterError struct {
+	Field    string
+	Operator FilterOperator
+	Err      error
+}
+
+func (e *FilterError) Error() string {
+	return fmt.Sprintf("filter %q %q: %v", e.Field, e.Operator, e.Err)
+}
+
+func (e *FilterError) Unwrap() error {
+	return e.Err
+}
+
+// The fields and operators an endpoint allows, the key is the field name in the query and also the column name
+// Example: d.FilterFields{"price": {d.FilterOperatorGte, d.FilterOperatorLte}, "status": {d.FilterOperatorIn}}
+type FilterFields map[string][]FilterOperator
+
+// A parsed filter condition
+type FilterCondition struct {
+	Field    string
+	Operator FilterOperator
+	Values   []string
+}
+
+var (
+	// filter[field] or filter[field][operator]
+	regexpFilterKey = regexp.MustCompile(`^\[([^\[\]]+)\](?:\[([^\[\]]+)\])?$`)
+)
+
+// Parse the filter conditions from the query, the operator defaults to eq
+// Conditions are sorted by field and operator so the generated SQL is stable
+// Example: ParseFilterQuery(c.Request.URL.Query(), d.FilterFields{"price": {d.FilterOperatorGte}})
+func ParseFilterQuery(query url.Values, allowed FilterFields) (conditions []FilterCondition, err error) {
+	for key, values := range query {
+		if !strings.HasPrefix(key, FieldNameFilter+"[") {
+			continue
+		}
+		match := regexpFilterKey.FindStringSubmatch(strings.TrimPrefix(key, FieldNameFilter))
+		if match == nil {
+			return nil, &FilterError{Field: key, Err: ErrFilterInvalidValue}
+		}
+
+		field, operator := match[1], FilterOperator(match[2])
+		if operator == "" {
+			operator = FilterOperatorEq
+		}
+
+		operators, ok := allowed[field]
+		if !ok || !regexpColumnIdentifier.MatchString(field) {
+			return nil, &FilterError{Field: field, Operator: operator, Err: ErrFilterFieldNotAllowed}
+		}
+		if !slices.Contains(operators, operator) {
+			return nil, &FilterError{Field: field, Operator: operator, Err: ErrFilterOperatorNotAllowed}
+		}
+		if len(values) == 0 {
+			continue
+		}
+
+		condition := FilterCondition{Field: field, Operator: operator, Values: []string{values[0]}}
+		switch operator {
+		case FilterOperatorIn:
+			condition.Values = strings.Split(values[0], ",")
+		case FilterOperatorBetween:
+			condition.Values = strings.Split(values[0], ",")
+			if len(condition.Values) != 2 {
+				return nil, &FilterError{Field: field, Operator: operator, Err: ErrFilterInvalidValue}
+			}
+		case FilterOperatorIsNull:
+			if _, err := strconv.ParseBool(values[0]); err != nil {
+				return nil, &FilterError{Field: field, Operator: operator, Err: ErrFilterInvalidValue}
+			}
+		}
+		conditions = append(conditions, condition)
+	}
+
+	sort.Slice(conditions, func(i, j int) bool {
+		if conditions[i].Field != conditions[j].Field {
+			return conditions[i].Field < conditions[j].Field
+		}
+		return conditions[i].Operator < conditions[j].Operator
+	})
+	return conditions, nil
+}
+
+// Generate GORM conditions from the filter conditions, all conditions are joined by AND
+func (l LibraryGorm) GenerateFilterQueries(tx *gorm.DB, conditions []FilterCondition) (*gorm.DB, error) {
+	for _, v := range conditions {
+		expression, err := v.expression(tx.Dialector.Name())
+		if err != nil {
+			return nil, err
+		}
+		tx = tx.Where(expression)
+	}
+	return tx, nil
+}
+
+func (f FilterCondition) expression(driver string) (clause.Expression, error) {
+	column := clause.Column{Name: f.Field}
+	args := make([]interface{}, len(f.Values))
+	for i, v := range f.Values {
+		args[i] = v
+	}
+
+	switch f.Operator {
+	case FilterOperatorEq:
+		return clause.Eq{Column: column, Value: args[0]}, nil
+	case FilterOperatorNe:
+		return clause.Neq{Column: column, Value: args[0]}, nil
+	case FilterOperatorGt:
+		return clause.Gt{Column: column, Value: args[0]}, nil
+	case FilterOperatorGte:
+		return clause.Gte{Column: column, Value: args[0]}, nil
+	case FilterOperatorLt:
+		return clause.Lt{Column: column, Value: args[0]}, nil
+	case FilterOperatorLte:
+		return clause.Lte{Column: column, Value: args[0]}, nil
+	case FilterOperatorIn:
+		return clause.IN{Column: column, Values: args}, nil
+	case FilterOperatorBetween:
+		return clause.Expr{SQL: "? BETWEEN ? AND ?", Vars: []interface{}{column, args[0], args[1]}}, nil
+	case FilterOperatorIsNull:
+		if b, _ := strconv.ParseBool(f.Values[0]); b {
+			return clause.Expr{SQL: "? IS NULL", Vars: []interface{}{column}}, nil
+		}
+		return clause.Expr{SQL: "? IS NOT NULL", Vars: []interface{}{column}}, nil
+	case FilterOperatorPrefix:
+		return clause.Expr{SQL: "? LIKE ? ESCAPE '" + likeEscapeCharacter + "'", Vars: []interface{}{column, escapeLike(f.Values[0], driver) + "%"}}, nil
+	case FilterOperatorExact:
+		switch driver {
+		case DatabaseDriverMySQL:
+			return clause.Expr{SQL: "? = BINARY ?", Vars: []interface{}{column, args[0]}}, nil
+		case DatabaseDriverSQLServer:
+			return clause.Expr{SQL: "? = ? COLLATE Latin1_General_CS_AS", Vars: []interface{}{column, args[0]}}, nil
+		}
+		// PostgreSQL and SQLite compare case-sensitively by default
+		return clause.Eq{Column: column, Value: args[0]}, nil
+	}
+	return nil, &FilterError{Field: f.Field, Operator: f.Operator, Err: ErrFilterOperatorNotAllowed}
+}
@@ -0,0 +1,93 @@
+package d
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var (
+	ErrFuzzyQueryNilFields        = errors.New("map is nil")
+	ErrFuzzyQueryInvalidColumn    = errors.New("invalid column name")
+	ErrFuzzyQueryColumnNotAllowed = errors.New("column is not allowed")
+	ErrFuzzyQueryNoAllowedColumns = errors.New("fuzzy query without a model requires allowed columns")
+)
+
+// Error of a single column in the fuzzy query, use errors.Is to check the reason
+type FuzzyQueryError struct {
+	Column string
+	Err    error
+}
+
+func (e *FuzzyQueryError) Error() string {
+	return fmt.Sprintf("fuzzy query column %q: %v", e.Column, e.Err)
+}
+
+func (e *FuzzyQueryError) Unwrap() error {
+	return e.Err
+}
+
+const (
+	// Escape character of LIKE, a backslash is avoided because its meaning in string literals differs between databases
+	likeEscapeCharacter = "!"
+)
+
+var (
+	// column or table.column
+	regexpColumnIdentifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+)
+
+// Generate lazy query parameters joined by AND, the fields are sorted so the generated SQL is stable
+// Column names are validated and quoted with open and close, values are escaped for the driver and matched with the operator
+// Example : generateFuzzyQueries(fields, "`", "`", "LIKE", DatabaseDriverMySQL)
+func generateFuzzyQueries(fields map[string]string, open, close, operator, driver string) (whereClause string, args []interface{}, err error) {
+	// If map is nil
+	if fields == nil {
+		return "", nil, ErrFuzzyQueryNilFields
+	}
+
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var conditions []string
+	for _, k := range keys {
+		// If there is no value, skip the current field
+		if len(fields[k]) == 0 {
+			continue
+		}
+		column, err := quoteColumn(k, open, close)
+		if err != nil {
+			return "", nil, err
+		}
+		conditions = append(conditions, column+" "+operator+" ? ESCAPE '"+likeEscapeCharacter+"'")
+		args = append(args, "%"+escapeLike(fields[k], driver)+"%")
+	}
+
+	return strings.Join(conditions, " AND "), args, nil
+}
+
+// Validate the column name and quote every part of it
+func quoteColumn(column, open, close string) (string, error) {
+	if !regexpColumnIdentifier.MatchString(column) {
+		return "", &FuzzyQueryError{Column: column, Err: ErrFuzzyQueryInvalidColumn}
+	}
+	parts := strings.Split(column, ".")
+	for i, v := range parts {
+		parts[i] = open + v + close
+	}
+	return strings.Join(parts, "."), nil
+}
+
+// Escape the LIKE wildcards of the driver, [ is only a wildcard in SQL Server
+func escapeLike(value, driver string) string {
+	e := likeEscapeCharacter
+	if driver == DatabaseDriverSQLServer {
+		return strings.NewReplacer(e, e+e, `%`, e+`%`, `_`, e+`_`, `[`, e+`[`).Replace(value)
+	}
+	return strings.NewReplacer(e, e+e, `%`, e+`%`, `_`, e+`_`).Replace(value)
+}
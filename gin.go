@@ -5,22 +5,41 @@ import (
 	"encoding/json"
 	"errors"
 	"io"
+	"mime"
+	"mime/multipart"
 	"net/http"
-	"strconv"
+	"net/url"
+	"sort"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
 	"gorm.io/gorm"
 )
 
 type Gin struct{}
 
+// The memory used to parse a multipart body, the rest is stored in temporary files, the same as gin
+const defaultMultipartMemory = 32 << 20
+
 // Returns a successful response in gin format
 func (g Gin) Success(c *gin.Context, a InterfaceApi) {
 	// If gin.Context is nil
 	if c == nil {
 		return
 	}
-	c.JSON(http.StatusOK, a.Success())
+	a = g.forRequest(c, a)
+	g.Render(c, http.StatusOK, a.Success())
+}
+
+// Write the response in the format negotiated by the Accept header, see NegotiateApiEncoder
+// Example: d.Gin{}.Render(c, http.StatusOK, api.Success())
+func (g Gin) Render(c *gin.Context, status int, data interface{}) {
+	// If gin.Context is nil
+	if c == nil {
+		return
+	}
+	c.Header("Vary", "Accept")
+	c.Render(status, apiRender{encoder: NegotiateApiEncoder(c.GetHeader("Accept")), data: data})
 }
 
 // Returns an error response in gin format
@@ -29,7 +48,21 @@ func (g Gin) Error(c *gin.Context, a InterfaceApi) {
 	if c == nil {
 		return
 	}
-	c.JSON(http.StatusOK, a.Error())
+	a = g.forRequest(c, a)
+	g.Render(c, apiHTTPStatus(a), a.Error())
+}
+
+// Returns an error response in gin format, the error is converted by ApiErrorFrom
+// Validation messages are in the language of the Accept-Language header
+// The api must implement InterfaceApiData, otherwise the api is responded as it is
+func (g Gin) ErrorFrom(c *gin.Context, a InterfaceApi, err error) {
+	if apiErr := NewValidationApiError(err, nil, requestLanguage(c)); apiErr != nil {
+		err = apiErr
+	}
+	if ad, ok := a.(InterfaceApiData); ok {
+		a = ad.WithError(err)
+	}
+	g.Error(c, a)
 }
 
 // Returns a pagination response in gin format
@@ -38,7 +71,16 @@ func (g Gin) Pagination(c *gin.Context, a InterfaceApi, p InterfacePagination) {
 	if c == nil {
 		return
 	}
-	c.JSON(http.StatusOK, a.Pagination(p))
+	a = g.forRequest(c, a)
+	g.Render(c, http.StatusOK, a.Pagination(p))
+}
+
+// Choose or fill the api by the request, such as the version of LibraryApiVersions, see InterfaceApiRequest
+func (g Gin) forRequest(c *gin.Context, a InterfaceApi) InterfaceApi {
+	if ar, ok := a.(InterfaceApiRequest); ok {
+		return ar.ForRequest(c.Request, c.Writer.Header())
+	}
+	return a
 }
 
 // Returns data or error response in gin format
@@ -54,6 +96,26 @@ func (g Gin) DataOrError(c *gin.Context, a InterfaceApi) {
 	}
 }
 
+// Bind the JSON body to obj, validation errors are returned as a 422 *ApiError with field errors
+// The field names are the JSON names renamed by the api.field and api.casing config, the messages are in the language of the Accept-Language header
+// Example:
+//
+//	if err := d.Gin{}.ShouldBindJSON(c, &user); err != nil {
+//		d.Gin{}.ErrorFrom(c, api, err)
+//		return
+//	}
+func (g Gin) ShouldBindJSON(c *gin.Context, obj interface{}) error {
+	// If gin.Context is nil
+	if c == nil {
+		return errors.New("gin.Context is nil")
+	}
+	err := c.ShouldBindJSON(obj)
+	if apiErr := NewValidationApiError(err, obj, requestLanguage(c)); apiErr != nil {
+		return apiErr
+	}
+	return err
+}
+
 // Generate lazy query parameters based on parameters and value
 // Example : GenerateFuzzyQuery(GORM_DB_QUERY, []string{"name", "sex"})
 func (g Gin) GenerateFuzzyQuery(c *gin.Context, tx *gorm.DB, fields []string) (*gorm.DB, error) {
@@ -72,7 +134,38 @@ func (g Gin) GenerateFuzzyQuery(c *gin.Context, tx *gorm.DB, fields []string) (*
 	}
 
 	var gorm LibraryGorm
-	return gorm.GenerateFuzzyQueries(tx, m)
+	return gorm.GenerateFuzzyQueries(tx, m, fields...)
+}
+
+// Generate filter conditions from the filter query parameters, such as ?filter[price][gte]=10&filter[status][in]=a,b
+// Example : GenerateFilterQuery(c, GORM_DB_QUERY, d.FilterFields{"price": {d.FilterOperatorGte}})
+func (g Gin) GenerateFilterQuery(c *gin.Context, tx *gorm.DB, fields FilterFields) (*gorm.DB, error) {
+	// If gin.Context is nil
+	if c == nil {
+		return nil, errors.New("gin.Context is nil")
+	}
+	// If fields is nil,no error will be reported and the original value will be returned
+	if fields == nil {
+		return tx, nil
+	}
+
+	conditions, err := ParseFilterQuery(c.Request.URL.Query(), fields)
+	if err != nil {
+		return nil, err
+	}
+
+	var gorm LibraryGorm
+	return gorm.GenerateFilterQueries(tx, conditions)
+}
+
+// Apply the sort query, returns the applied sort in the query format
+// Example : GenerateSortQuery(c, GORM_DB_QUERY, []string{"created_at", "name"}, "-created_at")
+func (g Gin) GenerateSortQuery(c *gin.Context, tx *gorm.DB, fields []string, default_sort string) (*gorm.DB, string, error) {
+	// If gin.Context is nil
+	if c == nil {
+		return nil, "", errors.New("gin.Context is nil")
+	}
+	return LibraryGorm{}.GenerateSortQueries(tx, c.Request.URL.Query(), fields, default_sort)
 }
 
 // Get list with fuzzy query
@@ -82,109 +175,205 @@ func (g Gin) GenerateFuzzyQuery(c *gin.Context, tx *gorm.DB, fields []string) (*
 // var data []database.Supplier
 // p, err := dg.GetListWithFuzzyQuery(query, nil, &data)
 func (g Gin) GetListWithFuzzyQuery(c *gin.Context, query *gorm.DB, fuzzy_query_field_name []string, data_list_pointer interface{}) (p InterfacePagination, err error) {
+	return g.GetListWithQuery(c, query, ListQuery{FuzzyFields: fuzzy_query_field_name}, data_list_pointer)
+}
+
+// Get list with fuzzy, filter and sort query
+// Example:
+//
+//	p, err := d.Gin{}.GetListWithQuery(c, query, d.ListQuery{
+//		FuzzyFields:  []string{"name"},
+//		FilterFields: d.FilterFields{"price": {d.FilterOperatorGte, d.FilterOperatorLte}, "status": {d.FilterOperatorIn}},
+//	}, &data)
+func (g Gin) GetListWithQuery(c *gin.Context, query *gorm.DB, q ListQuery, data_list_pointer interface{}) (p InterfacePagination, err error) {
 	// If gin.Context is nil
 	if c == nil {
 		return p, errors.New("gin.Context is nil")
 	}
+	return LibraryGorm{}.GetList(query, c.Request.URL.Query(), q, data_list_pointer)
+}
 
-	tx, err := g.GenerateFuzzyQuery(c, query, fuzzy_query_field_name)
-	if err != nil {
-		return p, err
+// Export the list with fuzzy, filter and sort query as a file, the format is read from the format query parameter, csv or xlsx
+// The Content-Type and Content-Disposition headers are written with the first bytes of the file
+// If nothing is written when an error is returned, such as an invalid query, the error can still be responded
+// Example:
+//
+//	var data []User
+//	if err := d.Gin{}.Export(c, query, d.ListQuery{FuzzyFields: []string{"name"}}, &data, "users"); err != nil && !c.Writer.Written() {
+//		d.Gin{}.ErrorFrom(c, api, err)
+//	}
+func (g Gin) Export(c *gin.Context, query *gorm.DB, q ListQuery, data_list_pointer interface{}, filename string) error {
+	// If gin.Context is nil
+	if c == nil {
+		return errors.New("gin.Context is nil")
 	}
-
-	var total int64
-	tx.Count(&total)
-	// Generate paginated data
-	f := LibraryGorm{}.Paginate(c.Request)
-	result := f(tx).Find(data_list_pointer)
-	if result.Error != nil {
-		return p, result.Error
+	values := c.Request.URL.Query()
+	format, err := ParseExportFormat(values)
+	if err != nil {
+		return err
 	}
 
-	page, err := strconv.Atoi(c.Query(FieldNamePaginationPage))
-	if err != nil {
-		page = 1
+	if filename == "" {
+		filename = "export"
 	}
-	pageSize, err := strconv.Atoi(c.Query(FieldNamePaginationPageSize))
-	if err != nil {
-		pageSize = 20
+	w := &exportResponseWriter{c: c, format: format, filename: filename + "." + string(format)}
+	err = LibraryGorm{}.Export(w, query.WithContext(c.Request.Context()), values, q, data_list_pointer, format)
+	if err != nil && c.Writer.Written() {
+		// The file is incomplete, the error is recorded in the gin context
+		_ = c.Error(err)
 	}
+	return err
+}
 
-	p = Pagination[InterfacePagination]{}.Get().Set(page, pageSize, int(total), nil)
+// Response writer of the export, the headers are written before the first bytes
+type exportResponseWriter struct {
+	c        *gin.Context
+	format   ExportFormat
+	filename string
+}
 
-	return p, nil
+func (w *exportResponseWriter) Write(p []byte) (int, error) {
+	if !w.c.Writer.Written() {
+		w.c.Header("Content-Type", w.format.ContentType())
+		w.c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": w.filename}))
+		w.c.Status(http.StatusOK)
+	}
+	return w.c.Writer.Write(p)
 }
 
-// API request interceptor in GIN, modify the returned fields
+// Send every batch to the client rather than buffering the whole file
+func (w *exportResponseWriter) Flush() {
+	w.c.Writer.Flush()
+}
+
+// API request interceptor in GIN, rename the fields of the request by the inverse of the api.field and api.casing config
+// The keys of the query, path params, JSON body, form-encoded and multipart body are renamed, the same rules rename the response
 func (g Gin) ModifyApiFieldName() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// Get custom field map
-		m := Config[InterfaceConfig]{}.Get().GetStringMap(ConfigPathApiField)
-		if len(m) == 0 {
+		// Get custom field map and casing
+		r := newApiFieldRenamer().reverse()
+		if r.empty() {
 			c.Next()
 			return
 		}
 
 		// Edit Query Keys
-		g.editQueryKeys(c, m)
+		g.editQueryKeys(c, r)
+		g.editParamKeys(c, r)
 
-		if c.Request.Body == nil {
+		if c.Request.Body == nil || c.Request.Body == http.NoBody {
 			c.Next()
 			return
 		}
 
-		var bodyBytes []byte
-		bodyBytes, err := io.ReadAll(c.Request.Body)
-		if err != nil || len(bodyBytes) == 0 {
-			c.Next()
-			return
+		switch c.ContentType() {
+		case binding.MIMEPOSTForm:
+			g.editFormKeys(c, r)
+		case binding.MIMEMultipartPOSTForm:
+			g.editMultipartFormKeys(c, r)
+		default:
+			g.editJSONKeys(c, r)
 		}
 
-		var bodyMap map[string]interface{}
-		err = json.Unmarshal(bodyBytes, &bodyMap)
-		if err != nil {
-			c.Next()
-			return
-		}
+		c.Next()
+	}
+}
 
-		g.recursionReplaceRequestKeys(bodyMap, m)
+// Edit JSON body keys, the body is left as it is if it is not JSON
+func (g Gin) editJSONKeys(c *gin.Context, r fieldRenamer) {
+	bodyBytes, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		return
+	}
+	// The body is read, restore it in case it is not modified
+	c.Request.Body = io.NopCloser(bytes.NewBuffer(bodyBytes))
+	if len(bodyBytes) == 0 {
+		return
+	}
 
-		modifiedBodyBytes, err := json.Marshal(bodyMap)
-		if err != nil {
-			c.Next()
-			return
-		}
+	var body interface{}
+	if err = json.Unmarshal(bodyBytes, &body); err != nil {
+		return
+	}
 
-		c.Request.Body = io.NopCloser(bytes.NewBuffer(modifiedBodyBytes))
-		c.Request.ContentLength = int64(len(modifiedBodyBytes))
+	modifiedBodyBytes, err := json.Marshal(r.rename(body))
+	if err != nil {
+		return
+	}
 
-		c.Next()
+	c.Request.Body = io.NopCloser(bytes.NewBuffer(modifiedBodyBytes))
+	c.Request.ContentLength = int64(len(modifiedBodyBytes))
+}
+
+// Edit form-encoded body keys, the parsed form is replaced so the body is not read again
+func (g Gin) editFormKeys(c *gin.Context, r fieldRenamer) {
+	if err := c.Request.ParseForm(); err != nil {
+		return
 	}
+	c.Request.PostForm = renameValues(c.Request.PostForm, r)
+	c.Request.Form = mergeValues(c.Request.URL.Query(), c.Request.PostForm)
 }
 
-func (g Gin) recursionReplaceRequestKeys(bodyMap, fieldMap map[string]interface{}) {
-	for key, value := range bodyMap {
-		if nestedMap, ok := value.(map[string]interface{}); ok {
-			g.recursionReplaceRequestKeys(nestedMap, fieldMap)
-		} else {
-			for k, v := range fieldMap {
-				if v.(string) == key {
-					bodyMap[k] = value
-					delete(bodyMap, key)
-				}
-			}
-		}
+// Edit multipart body keys, both the values and the files of the parsed form are renamed
+func (g Gin) editMultipartFormKeys(c *gin.Context, r fieldRenamer) {
+	if err := c.Request.ParseMultipartForm(defaultMultipartMemory); err != nil {
+		return
 	}
+	form := c.Request.MultipartForm
+	form.Value = renameValues(form.Value, r)
+	files := make(map[string][]*multipart.FileHeader, len(form.File))
+	for k, v := range form.File {
+		k = r.renameFormKey(k)
+		files[k] = append(files[k], v...)
+	}
+	form.File = files
+
+	c.Request.PostForm = form.Value
+	c.Request.Form = mergeValues(c.Request.URL.Query(), c.Request.PostForm)
 }
 
-// Edit Query Keys
-func (g Gin) editQueryKeys(c *gin.Context, fieldMap map[string]interface{}) {
-	for k, v := range fieldMap {
-		if subValue, ok := c.GetQuery(v.(string)); ok {
-			urlValue := c.Request.URL.Query()
-			urlValue.Set(k, subValue)
-			urlValue.Del(v.(string))
-			c.Request.URL.RawQuery = urlValue.Encode()
+// Edit Query Keys, the query is encoded once
+func (g Gin) editQueryKeys(c *gin.Context, r fieldRenamer) {
+	if c.Request.URL.RawQuery == "" {
+		return
+	}
+	c.Request.URL.RawQuery = renameValues(c.Request.URL.Query(), r).Encode()
+}
+
+// Edit path param keys
+func (g Gin) editParamKeys(c *gin.Context, r fieldRenamer) {
+	for i, v := range c.Params {
+		if name, ok := r.nameOf("", v.Key); ok {
+			c.Params[i].Key = name
 		}
 	}
 }
+
+// Rename the keys of the values, the values of keys with the same new name are merged
+func renameValues(values map[string][]string, r fieldRenamer) url.Values {
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	// The keys are visited in order, so that the merged values are in a deterministic order
+	sort.Strings(keys)
+
+	m := make(url.Values, len(values))
+	for _, k := range keys {
+		name := r.renameFormKey(k)
+		m[name] = append(m[name], values[k]...)
+	}
+	return m
+}
+
+// Merge the values like http.Request.Form, the values of b come first
+func mergeValues(a, b url.Values) url.Values {
+	m := make(url.Values, len(a)+len(b))
+	for k, v := range b {
+		m[k] = append(m[k], v...)
+	}
+	for k, v := range a {
+		m[k] = append(m[k], v...)
+	}
+	return m
+}
@@ -0,0 +1,193 @@
+package d
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Renaming the keys by the rules and then by the reverse in Gin.ModifyApiFieldName returns the request
+func TestGinModifyApiFieldNameRoundTrip(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	configs := []struct {
+		name   string
+		config string
+	}{
+		{"rules", `
+api:
+  field:
+    user_name: userName
+    items: list
+`},
+		{"path rules", `
+api:
+  field:
+    data: payload
+    "data.items[].user_name": login
+`},
+		{"several keys with the same name", `
+api:
+  field:
+    user_name: name
+    nick_name: name
+`},
+		{"casing with rules", `
+api:
+  casing: camel
+  field:
+    user_name: userId
+    created_at: created
+`},
+		{"swapped keys", `
+api:
+  field:
+    user_name: user_id
+    user_id: user_name
+`},
+	}
+
+	bodies := []string{
+		`{"user_name":"a","user_id":1,"created_at":2,"nick_name":"b"}`,
+		`{"data":{"user_name":"a","items":[{"user_name":"b","user_id":1},{"nick_name":"c"}]}}`,
+		`[{"user_name":"a","items":[{"user_id":1}]},{"created_at":2}]`,
+	}
+	values := url.Values{
+		"user_name":               {"a", "b"},
+		"user_id":                 {"1"},
+		"nick_name":               {"c"},
+		"items[0][user_name]":     {"d"},
+		"data[items][1][user_id]": {"2"},
+		"filter[created_at][gte]": {"3"},
+	}
+	params := []string{"user_name", "user_id"}
+
+	for _, tc := range configs {
+		t.Run(tc.name, func(t *testing.T) {
+			initTestConfig(t, tc.config)
+			r := newApiFieldRenamer()
+
+			for _, body := range bodies {
+				input := decodeTestJSON(t, body)
+				b, err := json.Marshal(r.rename(input))
+				if err != nil {
+					t.Fatal(err)
+				}
+				req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(b))
+				req.Header.Set("Content-Type", "application/json")
+				c := serveTestRequest(t, "/", req)
+				got, err := io.ReadAll(c.Request.Body)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if v := decodeTestJSON(t, string(got)); !reflect.DeepEqual(v, input) {
+					t.Errorf("JSON body %s is renamed to %s and back to %s", body, b, got)
+				}
+			}
+
+			forward := renameValues(values, r)
+
+			req := httptest.NewRequest(http.MethodPost, "/?"+forward.Encode(), nil)
+			c := serveTestRequest(t, "/", req)
+			assertTestValues(t, "query", c.Request.URL.Query(), values)
+
+			req = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(forward.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			c = serveTestRequest(t, "/", req)
+			assertTestValues(t, "form", c.Request.PostForm, values)
+
+			var buf bytes.Buffer
+			w := multipart.NewWriter(&buf)
+			for k, v := range forward {
+				for _, s := range v {
+					if err := w.WriteField(k, s); err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+			file, err := w.CreateFormFile(r.renameFormKey("items[0][user_name]"), "a.txt")
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, _ = file.Write([]byte("a"))
+			if err = w.Close(); err != nil {
+				t.Fatal(err)
+			}
+			req = httptest.NewRequest(http.MethodPost, "/", &buf)
+			req.Header.Set("Content-Type", w.FormDataContentType())
+			c = serveTestRequest(t, "/", req)
+			assertTestValues(t, "multipart", c.Request.MultipartForm.Value, values)
+			if _, ok := c.Request.MultipartForm.File["items[0][user_name]"]; !ok || len(c.Request.MultipartForm.File) != 1 {
+				t.Errorf("multipart files are renamed back to %v", c.Request.MultipartForm.File)
+			}
+
+			route := ""
+			for _, p := range params {
+				name := p
+				if n, ok := r.nameOf("", p); ok {
+					name = n
+				}
+				route += "/:" + name
+			}
+			c = serveTestRequest(t, route, httptest.NewRequest(http.MethodPost, "/1/2", nil))
+			for i, p := range params {
+				if c.Params[i].Key != p {
+					t.Errorf("path param %s is renamed back to %s", p, c.Params[i].Key)
+				}
+			}
+		})
+	}
+}
+
+// Serve the request by a router with Gin.ModifyApiFieldName, returns the context of the handler
+func serveTestRequest(t *testing.T, route string, req *http.Request) *gin.Context {
+	t.Helper()
+	var ctx *gin.Context
+	router := gin.New()
+	router.Use(Gin{}.ModifyApiFieldName())
+	router.POST(route, func(c *gin.Context) {
+		ctx = c.Copy()
+		ctx.Request = c.Request
+		// The body is read in the handler, as it is closed after the request
+		if c.ContentType() == "application/json" {
+			b, _ := io.ReadAll(c.Request.Body)
+			ctx.Request.Body = io.NopCloser(bytes.NewReader(b))
+		}
+	})
+	router.ServeHTTP(httptest.NewRecorder(), req)
+	if ctx == nil {
+		t.Fatalf("%s %s is not handled", req.Method, req.URL)
+	}
+	return ctx
+}
+
+func assertTestValues(t *testing.T, name string, got, want map[string][]string) {
+	t.Helper()
+	keys := func(m map[string][]string) []string {
+		list := make([]string, 0, len(m))
+		for k := range m {
+			list = append(list, k)
+		}
+		sort.Strings(list)
+		return list
+	}
+	if !reflect.DeepEqual(keys(got), keys(want)) {
+		t.Errorf("%s keys are renamed back to %v, want %v", name, keys(got), keys(want))
+		return
+	}
+	for k, v := range want {
+		if !reflect.DeepEqual(got[k], v) {
+			t.Errorf("%s values of %s are %v, want %v", name, k, got[k], v)
+		}
+	}
+}
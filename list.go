@@ -0,0 +1,115 @@
+package d
+
+import (
+	"net/url"
+	"slices"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// Query options of the list endpoint, the fields not declared here cannot be queried
+type ListQuery struct {
+	FuzzyFields  []string     // Fields matched with LIKE %value%, such as ?name=John
+	FilterFields FilterFields // Fields and operators of the filter query, such as ?filter[price][gte]=10
+	SortFields   []string     // Fields of the sort query, such as ?sort=-created_at,name
+	DefaultSort  string       // Used when there is no sort query, such as -created_at
+	CountMode    CountMode    // How the total is counted, the total is counted exactly and the mode is not reported by default
+}
+
+// Apply the sort query of the values, returns the applied sort in the query format
+// If no field is declared or the sort query is empty, such as ?sort=, only the default sort is applied
+// The sort is applied to tx right away rather than as a scope, so gorm.DB.Count still removes it from the count query
+func (l LibraryGorm) GenerateSortQueries(tx *gorm.DB, values url.Values, fields []string, default_sort string) (*gorm.DB, string, error) {
+	value, ok := values[FieldNamePaginationSort]
+	if !ok || len(value) == 0 || strings.TrimSpace(value[0]) == "" || len(fields) == 0 {
+		value = []string{default_sort}
+		// The default sort is declared by the endpoint itself
+		fields = slices.Clone(fields)
+		for _, v := range strings.Split(default_sort, ",") {
+			fields = append(fields, strings.TrimPrefix(strings.TrimSpace(v), "-"))
+		}
+	}
+
+	columns, err := ParseSortQuery(value[0], fields)
+	if err != nil {
+		return nil, "", err
+	}
+	return l.Sort(columns)(tx), FormatSortQuery(columns), nil
+}
+
+// Apply the fuzzy, filter and sort query of the values, returns the applied sort
+func (l LibraryGorm) ApplyListQuery(tx *gorm.DB, values url.Values, q ListQuery) (*gorm.DB, string, error) {
+	var err error
+	if q.FuzzyFields != nil {
+		var m = make(map[string]string)
+		for _, v := range q.FuzzyFields {
+			m[v] = values.Get(v)
+		}
+		if tx, err = l.GenerateFuzzyQueries(tx, m, q.FuzzyFields...); err != nil {
+			return nil, "", err
+		}
+	}
+
+	if q.FilterFields != nil {
+		conditions, err := ParseFilterQuery(values, q.FilterFields)
+		if err != nil {
+			return nil, "", err
+		}
+		if tx, err = l.GenerateFilterQueries(tx, conditions); err != nil {
+			return nil, "", err
+		}
+	}
+
+	return l.GenerateSortQueries(tx, values, q.SortFields, q.DefaultSort)
+}
+
+// Get the list of a page with fuzzy, filter and sort query
+// Example:
+// p, err := d.LibraryGorm{}.GetList(query, r.URL.Query(), d.ListQuery{FuzzyFields: []string{"name"}}, &data)
+func (l LibraryGorm) GetList(query *gorm.DB, values url.Values, q ListQuery, data_list_pointer interface{}) (p InterfacePagination, err error) {
+	tx, sort, err := l.ApplyListQuery(query, values, q)
+	if err != nil {
+		return p, err
+	}
+
+	total, mode, err := l.CountWithMode(tx, q.CountMode, data_list_pointer)
+	if err != nil {
+		return p, err
+	}
+	// Generate paginated data, the reported page and page size are the ones executed
+	page, pageSize := l.pageAndPageSize(values)
+	tx = tx.Offset((page - 1) * pageSize).Limit(pageSize)
+	// Fetch one more row to know whether there is a next page without relying on the total
+	if mode != CountModeExact {
+		tx = tx.Limit(pageSize + 1)
+	}
+	result := tx.Find(data_list_pointer)
+	if result.Error != nil {
+		return p, result.Error
+	}
+
+	hasMore := int64(page*pageSize) < total
+	if mode != CountModeExact {
+		list, more, err := truncateList(data_list_pointer, pageSize)
+		if err != nil {
+			return p, err
+		}
+		hasMore = more
+		// The estimate cannot be less than the rows already seen
+		if seen := int64((page-1)*pageSize + list.Len()); mode == CountModeEstimated && total < seen {
+			total = seen
+		}
+	}
+
+	p = Pagination[InterfacePagination]{}.Get().Set(page, pageSize, int(total), data_list_pointer)
+	// The count mode is only reported when requested, the reported mode is the one that ran
+	if pc, ok := p.(InterfacePaginationCount); ok && q.CountMode != "" {
+		p = pc.SetCount(mode, hasMore)
+	}
+	if ps, ok := p.(InterfacePaginationSort); ok {
+		p = ps.SetSort(sort)
+	}
+
+	return p, nil
+}
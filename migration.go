@@ -0,0 +1,345 @@
+package d
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Optional dialect interface, implement it so parallel migration runners do not race
+// The lock belongs to conn, it is released by Unlock on the same connection or when the connection is closed
+// The statements run on conn directly, as a plugin such as dbresolver may send the statements of a *gorm.DB to another connection
+type InterfaceDialectLock interface {
+	Lock(ctx context.Context, conn *sql.Conn, name string) error
+	Unlock(ctx context.Context, conn *sql.Conn, name string) error
+}
+
+// Versioned migration, either the functions or the SQL are used, the functions take precedence
+type Migration struct {
+	Version int64 // Migrations are applied in ascending order of version, such as 20240601120000
+	Name    string
+	Up      func(tx *gorm.DB) error
+	Down    func(tx *gorm.DB) error
+	UpSQL   string
+	DownSQL string
+}
+
+// Record of an applied migration
+type SchemaMigration struct {
+	Version   int64 `gorm:"primaryKey;autoIncrement:false"`
+	Name      string
+	AppliedAt time.Time
+}
+
+func (SchemaMigration) TableName() string {
+	return MigrationTableName
+}
+
+// Status of a migration
+type MigrationStatus struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+}
+
+var (
+	MigrationTableName = "schema_migrations"
+	MigrationLockName  = "schema_migrations"
+)
+
+var (
+	ErrMigrationDuplicateVersion = errors.New("duplicate migration version")
+	ErrMigrationUnknownVersion   = errors.New("applied migration version is not in the migration list")
+	ErrMigrationIrreversible     = errors.New("migration has no down step")
+)
+
+// Migration runner
+// Example:
+// m := d.Migrator{Migrations: migrations}
+// applied, err := m.Up(ctx)
+type Migrator struct {
+	DB         *gorm.DB // If nil, the registered database is used
+	Migrations []Migration
+	DryRun     bool // Up and DownTo only return the migrations that would run, nothing is written, not even the schema_migrations table
+}
+
+// Get the status of every migration, ordered by version, nothing is written
+func (m Migrator) Status(ctx context.Context) (list []MigrationStatus, err error) {
+	m.DryRun = true
+	err = m.run(ctx, func(tx *gorm.DB, migrations []Migration, applied map[int64]SchemaMigration) error {
+		for _, v := range migrations {
+			s := MigrationStatus{Migration: v}
+			if a, ok := applied[v.Version]; ok {
+				s.Applied, s.AppliedAt = true, a.AppliedAt
+			}
+			list = append(list, s)
+		}
+		return nil
+	})
+	return list, err
+}
+
+// Apply all pending migrations in ascending order, returns the applied migrations
+func (m Migrator) Up(ctx context.Context) (list []Migration, err error) {
+	err = m.run(ctx, func(tx *gorm.DB, migrations []Migration, applied map[int64]SchemaMigration) error {
+		for _, v := range migrations {
+			if _, ok := applied[v.Version]; ok {
+				continue
+			}
+			if !m.DryRun {
+				err := tx.Transaction(func(tx *gorm.DB) error {
+					if err := v.apply(tx, v.Up, v.UpSQL); err != nil {
+						return err
+					}
+					return tx.Create(&SchemaMigration{Version: v.Version, Name: v.Name, AppliedAt: time.Now()}).Error
+				})
+				if err != nil {
+					return fmt.Errorf("migration %d %s: %w", v.Version, v.Name, err)
+				}
+			}
+			list = append(list, v)
+		}
+		return nil
+	})
+	return list, err
+}
+
+// Roll back the applied migrations with a version greater than the given version in descending order
+// DownTo(0) rolls back all migrations, returns the rolled back migrations
+func (m Migrator) DownTo(ctx context.Context, version int64) (list []Migration, err error) {
+	err = m.run(ctx, func(tx *gorm.DB, migrations []Migration, applied map[int64]SchemaMigration) error {
+		byVersion := make(map[int64]Migration, len(migrations))
+		for _, v := range migrations {
+			byVersion[v.Version] = v
+		}
+
+		var versions []int64
+		for k := range applied {
+			if k > version {
+				versions = append(versions, k)
+			}
+		}
+		sort.Slice(versions, func(i, j int) bool { return versions[i] > versions[j] })
+
+		for _, k := range versions {
+			v, ok := byVersion[k]
+			if !ok {
+				return fmt.Errorf("%w: %d", ErrMigrationUnknownVersion, k)
+			}
+			if v.Down == nil && v.DownSQL == "" {
+				return fmt.Errorf("%w: %d %s", ErrMigrationIrreversible, v.Version, v.Name)
+			}
+			if !m.DryRun {
+				err := tx.Transaction(func(tx *gorm.DB) error {
+					if err := v.apply(tx, v.Down, v.DownSQL); err != nil {
+						return err
+					}
+					return tx.Delete(&SchemaMigration{Version: v.Version}).Error
+				})
+				if err != nil {
+					return fmt.Errorf("migration %d %s: %w", v.Version, v.Name, err)
+				}
+			}
+			list = append(list, v)
+		}
+		return nil
+	})
+	return list, err
+}
+
+// Run fc on a single connection holding the migration lock, with the sorted migrations and the applied records
+func (m Migrator) run(ctx context.Context, fc func(tx *gorm.DB, migrations []Migration, applied map[int64]SchemaMigration) error) error {
+	migrations, err := m.sorted()
+	if err != nil {
+		return err
+	}
+
+	db := m.DB
+	if db == nil {
+		db = Database[LibraryGorm]{}.Get().DB
+	}
+	db = db.WithContext(ctx).Scopes(LibraryGorm{}.UsePrimary())
+
+	return db.Connection(func(tx *gorm.DB) (err error) {
+		// The lock is taken and released on the connection held by db.Connection
+		conn, ok := tx.Statement.ConnPool.(*sql.Conn)
+		if dialect, e := GetDatabaseDialect(tx.Dialector.Name()); e == nil && ok {
+			if locker, ok := dialect.(InterfaceDialectLock); ok {
+				if err := locker.Lock(ctx, conn, MigrationLockName); err != nil {
+					return err
+				}
+				defer func() {
+					// The lock is released even if the context is canceled
+					if e := locker.Unlock(context.WithoutCancel(ctx), conn, MigrationLockName); err == nil {
+						err = e
+					}
+				}()
+			}
+		}
+
+		// A dry run does not create the table, without the table no migration is applied
+		var records []SchemaMigration
+		if !m.DryRun {
+			if err := tx.AutoMigrate(&SchemaMigration{}); err != nil {
+				return err
+			}
+		}
+		if !m.DryRun || tx.Migrator().HasTable(&SchemaMigration{}) {
+			if err := tx.Find(&records).Error; err != nil {
+				return err
+			}
+		}
+		applied := make(map[int64]SchemaMigration, len(records))
+		for _, v := range records {
+			applied[v.Version] = v
+		}
+		return fc(tx, migrations, applied)
+	})
+}
+
+// Sort the migrations by version and check for duplicates
+func (m Migrator) sorted() ([]Migration, error) {
+	migrations := append([]Migration(nil), m.Migrations...)
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	for i := 1; i < len(migrations); i++ {
+		if migrations[i].Version == migrations[i-1].Version {
+			return nil, fmt.Errorf("%w: %d", ErrMigrationDuplicateVersion, migrations[i].Version)
+		}
+	}
+	return migrations, nil
+}
+
+// Run the function, or each statement of the SQL
+func (v Migration) apply(tx *gorm.DB, fc func(tx *gorm.DB) error, sql string) error {
+	if fc != nil {
+		return fc(tx)
+	}
+	for _, statement := range splitSQLStatements(sql, tx.Dialector.Name()) {
+		if err := tx.Exec(statement).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var (
+	// 20240601120000_create_users.up.sql
+	regexpMigrationFileName = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+	// $$ or $tag$ of PostgreSQL
+	regexpDollarQuoteTag = regexp.MustCompile(`^\$([A-Za-z_][A-Za-z0-9_]*)?\$`)
+)
+
+// Load SQL migrations from a directory, the file names are <version>_<name>.up.sql and <version>_<name>.down.sql
+// Example:
+// //go:embed migrations
+// var migrationFiles embed.FS
+// migrations, err := d.LoadSQLMigrations(migrationFiles, "migrations")
+func LoadSQLMigrations(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int64]*Migration{}
+	for _, entry := range entries {
+		match := regexpMigrationFileName.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			continue
+		}
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		b, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		v, ok := byVersion[version]
+		if !ok {
+			v = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = v
+		} else if v.Name != match[2] {
+			return nil, fmt.Errorf("%w: %d", ErrMigrationDuplicateVersion, version)
+		}
+		if match[3] == "up" {
+			v.UpSQL = string(b)
+		} else {
+			v.DownSQL = string(b)
+		}
+	}
+
+	var migrations []Migration
+	for _, v := range byVersion {
+		migrations = append(migrations, *v)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// Split SQL into statements by the semicolons outside of quotes and comments
+// The dollar-quoted bodies of PostgreSQL, such as $$ ... $$ of a function, and the backslash escapes of MySQL are taken into account
+func splitSQLStatements(sql, driver string) (statements []string) {
+	start := 0
+	add := func(end int) {
+		if s := strings.TrimSpace(sql[start:end]); s != "" {
+			statements = append(statements, s)
+		}
+	}
+	// The index after the end, or the end of sql if it is not found
+	skipTo := func(i int, end string) int {
+		if j := strings.Index(sql[i:], end); j >= 0 {
+			return i + j + len(end)
+		}
+		return len(sql)
+	}
+
+	for i := 0; i < len(sql); {
+		c := sql[i]
+		switch {
+		case c == '\'' || c == '"' || c == '`':
+			// A doubled quote is read as two quoted strings, which does not change the split
+			for i++; i < len(sql) && sql[i] != c; i++ {
+				if sql[i] == '\\' && driver == DatabaseDriverMySQL {
+					i++
+				}
+			}
+			i++
+		case strings.HasPrefix(sql[i:], "--") || (c == '#' && driver == DatabaseDriverMySQL):
+			i = skipTo(i, "\n")
+		case strings.HasPrefix(sql[i:], "/*"):
+			i = skipTo(i+2, "*/")
+		case c == '$' && driver == DatabaseDriverPostgreSQL && (i == 0 || !isSQLIdentifierByte(sql[i-1])):
+			tag := regexpDollarQuoteTag.FindString(sql[i:])
+			if tag == "" {
+				i++
+				break
+			}
+			i = skipTo(i+len(tag), tag)
+		case c == ';':
+			add(i)
+			i++
+			start = i
+		default:
+			i++
+		}
+	}
+	if start < len(sql) {
+		add(len(sql))
+	}
+	return statements
+}
+
+func isSQLIdentifierByte(c byte) bool {
+	return c == '_' || c == '$' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
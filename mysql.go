@@ -1,30 +1,113 @@
 package d
 
-import "errors"
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"reflect"
+	"strconv"
+
+	mysqlDriver "github.com/go-sql-driver/mysql"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+)
 
 type MySQL struct{}
 
+// Build the DSN, the host can contain the port, such as 127.0.0.1:3306
+func (m MySQL) Dsn(host, name, user, password string) string {
+	return user + ":" + password + "@tcp(" + host + ")/" + name + "?charset=utf8mb4&parseTime=True&loc=Local"
+}
+
+func (m MySQL) Open(dsn string) gorm.Dialector {
+	return mysql.Open(dsn)
+}
+
 // Generate lazy query parameters based on parameters and value
+// Column names are validated and quoted, % and _ in the values are matched literally
 // Example : GenerateFuzzyQueries(map[string]string{"name": "John", "sex": "female"})
 func (m MySQL) GenerateFuzzyQueries(fields map[string]string) (whereClause string, args []interface{}, err error) {
-	// If map is nil
-	if fields == nil {
-		return "", nil, errors.New("map is nil")
+	return generateFuzzyQueries(fields, "`", "`", "LIKE", DatabaseDriverMySQL)
+}
+
+// Estimate the number of rows of the query without COUNT(*)
+// A query without conditions reads TABLE_ROWS from information_schema, otherwise the rows of EXPLAIN are used
+func (m MySQL) EstimateCount(tx *gorm.DB, data_list_pointer interface{}) (int64, error) {
+	list := reflect.New(reflect.TypeOf(data_list_pointer).Elem()).Interface()
+	stmt := tx.Session(&gorm.Session{DryRun: true}).Find(list).Statement
+	if stmt.Error != nil {
+		return 0, stmt.Error
 	}
+	db := tx.Session(&gorm.Session{NewDB: true})
 
-	for k, v := range fields {
-		// If there is no value, skip the current field
-		if len(v) == 0 {
-			continue
+	_, where := stmt.Clauses["WHERE"]
+	_, groupBy := stmt.Clauses["GROUP BY"]
+	if !where && !groupBy && len(stmt.Joins) == 0 && stmt.Table != "" {
+		var rows sql.NullInt64
+		err := db.Raw("SELECT TABLE_ROWS FROM information_schema.TABLES WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ?", stmt.Table).Row().Scan(&rows)
+		if err == nil && rows.Valid {
+			return rows.Int64, nil
 		}
-		// If whereClause is empty, it is the first traversal, otherwise just addthe OR clause
-		if len(whereClause) == 0 {
-			whereClause = k + " LIKE ?"
-		} else {
-			whereClause += " AND " + k + " LIKE ?"
+	}
+
+	rows, err := db.Raw("EXPLAIN "+stmt.SQL.String(), stmt.Vars...).Rows()
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return 0, err
+	}
+	var index = -1
+	for i, v := range columns {
+		if v == "rows" {
+			index = i
 		}
-		args = append(args, "%"+v+"%")
 	}
+	if index < 0 || !rows.Next() {
+		return 0, errors.New("EXPLAIN does not return the rows column")
+	}
+
+	// The rows of the first table of the plan, which drives the query
+	values := make([]sql.RawBytes, len(columns))
+	dest := make([]interface{}, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	if err = rows.Scan(dest...); err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(string(values[index]), 10, 64)
+}
+
+// Take a named lock for the connection, waits until it is available
+func (m MySQL) Lock(ctx context.Context, conn *sql.Conn, name string) error {
+	var result sql.NullInt64
+	if err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, -1)", name).Scan(&result); err != nil {
+		return err
+	}
+	if result.Int64 != 1 {
+		return errors.New("failed to get lock " + name)
+	}
+	return nil
+}
+
+// Release the named lock of the connection
+func (m MySQL) Unlock(ctx context.Context, conn *sql.Conn, name string) error {
+	var result sql.NullInt64
+	if err := conn.QueryRowContext(ctx, "SELECT RELEASE_LOCK(?)", name).Scan(&result); err != nil {
+		return err
+	}
+	if result.Int64 != 1 {
+		return errors.New("failed to release lock " + name)
+	}
+	return nil
+}
 
-	return whereClause, args, nil
+// Deadlock (1213) and lock wait timeout (1205) can succeed when the transaction is retried
+func (m MySQL) IsRetryableError(err error) bool {
+	var mysqlErr *mysqlDriver.MySQLError
+	return errors.As(err, &mysqlErr) && (mysqlErr.Number == 1213 || mysqlErr.Number == 1205)
 }
@@ -1,5 +1,10 @@
 package d
 
+import (
+	"errors"
+	"reflect"
+)
+
 // Pagination interface, implement at least the following methods to facilitate internal calls in the devtool library
 type InterfacePagination interface {
 	Init()
@@ -7,11 +12,47 @@ type InterfacePagination interface {
 	ToMap() map[string]interface{}
 }
 
+// Optional pagination interface, implement it to report how the total was counted
+type InterfacePaginationCount interface {
+	SetCount(mode CountMode, has_more bool) InterfacePagination
+}
+
+// Optional pagination interface, implement it to echo the applied sort in the response
+type InterfacePaginationSort interface {
+	SetSort(sort string) InterfacePagination
+}
+
 var (
-	FieldNamePaginationPage     = "page"
-	FieldNamePaginationPageSize = "page_size"
-	FieldNamePaginationTotal    = "total"
-	FieldNamePaginationList     = "list"
+	FieldNamePaginationPage      = "page"
+	FieldNamePaginationPageSize  = "page_size"
+	FieldNamePaginationTotal     = "total"
+	FieldNamePaginationList      = "list"
+	FieldNamePaginationSort      = "sort"
+	FieldNamePaginationHasMore   = "has_more"
+	FieldNamePaginationCountMode = "count_mode"
+
+	FieldNamePaginationCursor     = "cursor"
+	FieldNamePaginationNextCursor = "next_cursor"
+	FieldNamePaginationPrevCursor = "prev_cursor"
+)
+
+// How the total of a list query is counted
+type CountMode string
+
+const (
+	CountModeExact     CountMode = "exact"     // COUNT(*), the default
+	CountModeSkip      CountMode = "skip"      // No total, only has_more by fetching page_size+1 rows
+	CountModeEstimated CountMode = "estimated" // Estimated by the dialect, such as EXPLAIN on MySQL, falls back to exact and is reported as exact
+)
+
+const (
+	ConfigPathPaginationDefaultPageSize = "pagination.default_page_size"
+	ConfigPathPaginationMaxPageSize     = "pagination.max_page_size"
+)
+
+var (
+	DefaultPaginationPageSize    = 10
+	DefaultPaginationMaxPageSize = 100
 )
 
 var (
@@ -39,10 +80,13 @@ func (p Pagination[T]) Get() T {
 
 // Pagination library
 type LibraryPagination struct {
-	Page     int
-	PageSize int
-	Total    int
-	DataList interface{}
+	Page      int
+	PageSize  int
+	Total     int
+	Sort      string
+	CountMode CountMode
+	HasMore   bool
+	DataList  interface{}
 }
 
 // Initialization
@@ -59,12 +103,103 @@ func (l LibraryPagination) Set(page, page_size, total int, datalist interface{})
 	}
 }
 
+// Set the applied sort, such as -created_at,name
+func (l LibraryPagination) SetSort(sort string) InterfacePagination {
+	l.Sort = sort
+	return l
+}
+
+// Set the count mode that ran and whether there is a next page, both are reported by ToMap
+func (l LibraryPagination) SetCount(mode CountMode, has_more bool) InterfacePagination {
+	l.CountMode = mode
+	l.HasMore = has_more
+	return l
+}
+
 // Pagination to map
+// The sort is only included when it is applied, the count mode and has_more only when the count mode is set
 func (l LibraryPagination) ToMap() map[string]interface{} {
-	return map[string]interface{}{
+	m := map[string]interface{}{
 		FieldNamePaginationPage:     l.Page,
 		FieldNamePaginationPageSize: l.PageSize,
 		FieldNamePaginationTotal:    l.Total,
 		FieldNamePaginationList:     l.DataList,
 	}
+	if l.Sort != "" {
+		m[FieldNamePaginationSort] = l.Sort
+	}
+	if l.CountMode == "" {
+		return m
+	}
+	m[FieldNamePaginationCountMode] = l.CountMode
+	m[FieldNamePaginationHasMore] = l.HasMore
+	if l.CountMode == CountModeSkip {
+		delete(m, FieldNamePaginationTotal)
+	}
+	return m
+}
+
+// Cursor pagination library, used with LibraryGorm.PaginateCursor
+// Example:
+// page_size, next, prev, err := d.LibraryGorm{}.PaginateCursor(c.Request, columns...)(query, &data)
+// p := d.LibraryCursorPagination{}.Set(0, page_size, 0, data).(d.LibraryCursorPagination).SetCursor(next, prev)
+type LibraryCursorPagination struct {
+	PageSize   int
+	NextCursor string
+	PrevCursor string
+	Sort       string
+	DataList   interface{}
+}
+
+// Initialization
+func (l LibraryCursorPagination) Init() {
+	Pagination[LibraryCursorPagination]{}.Init(LibraryCursorPagination{})
+}
+
+// Page and total are ignored, the position is carried by the cursors
+func (l LibraryCursorPagination) Set(page, page_size, total int, datalist interface{}) InterfacePagination {
+	l.PageSize = page_size
+	l.DataList = datalist
+	return l
+}
+
+// Set the next and previous cursors, an empty string means there is no page in that direction
+func (l LibraryCursorPagination) SetCursor(next_cursor, prev_cursor string) LibraryCursorPagination {
+	l.NextCursor = next_cursor
+	l.PrevCursor = prev_cursor
+	return l
+}
+
+// Set the applied sort, such as -created_at,name
+func (l LibraryCursorPagination) SetSort(sort string) InterfacePagination {
+	l.Sort = sort
+	return l
+}
+
+// Pagination to map
+func (l LibraryCursorPagination) ToMap() map[string]interface{} {
+	m := map[string]interface{}{
+		FieldNamePaginationPageSize:   l.PageSize,
+		FieldNamePaginationNextCursor: l.NextCursor,
+		FieldNamePaginationPrevCursor: l.PrevCursor,
+		FieldNamePaginationList:       l.DataList,
+	}
+	// The sort is only included when it is applied
+	if l.Sort != "" {
+		m[FieldNamePaginationSort] = l.Sort
+	}
+	return m
+}
+
+// Truncate the list to n elements, returns whether it had more
+func truncateList(data_list_pointer interface{}, n int) (list reflect.Value, has_more bool, err error) {
+	list = reflect.Indirect(reflect.ValueOf(data_list_pointer))
+	if list.Kind() != reflect.Slice {
+		return list, false, errors.New("data_list_pointer must be a pointer to a slice")
+	}
+	has_more = list.Len() > n
+	if has_more {
+		list.Set(list.Slice(0, n))
+	}
+	return list, has_more, nil
 }
@@ -0,0 +1,62 @@
+package d
+
+import (
+	"database/sql"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+var (
+	poolConfigListenerOnce sync.Once
+)
+
+// Apply the connection pool config to the registered database and its replicas
+func (l LibraryGorm) ApplyPoolConfig() error {
+	sqlDB, err := Database[LibraryGorm]{}.Get().DB.DB()
+	if err != nil {
+		return err
+	}
+	l.applyPoolConfig(sqlDB)
+
+	databaseReplicasMutex.RLock()
+	defer databaseReplicasMutex.RUnlock()
+	for _, v := range databaseReplicas {
+		if replica := v.db.Load(); replica != nil {
+			l.applyPoolConfig(replica)
+		}
+	}
+	return nil
+}
+
+func (l LibraryGorm) applyPoolConfig(sqlDB *sql.DB) {
+	conf := Config[InterfaceConfig]{}.Get()
+	sqlDB.SetMaxOpenConns(conf.GetIntWithDefault(ConfigPathDatabaseMaxOpenConns, DefaultDatabaseMaxOpenConns))
+	sqlDB.SetMaxIdleConns(conf.GetIntWithDefault(ConfigPathDatabaseMaxIdleConns, DefaultDatabaseMaxIdleConns))
+	sqlDB.SetConnMaxLifetime(time.Second * time.Duration(conf.GetIntWithDefault(ConfigPathDatabaseConnMaxLifetime, DefaultDatabaseConnMaxLifetime)))
+	sqlDB.SetConnMaxIdleTime(time.Second * time.Duration(conf.GetIntWithDefault(ConfigPathDatabaseConnMaxIdleTime, DefaultDatabaseConnMaxIdleTime)))
+}
+
+// Re-apply the pool limits when the config file changes, only supported by LibraryViper
+func (l LibraryGorm) watchPoolConfig() {
+	poolConfigListenerOnce.Do(func() {
+		conf := Config[InterfaceConfig]{}.Get()
+		if v, ok := conf.(LibraryViper); ok {
+			v.AddConfigChangeListener(func(e fsnotify.Event) {
+				if l.State() == DatabaseStateReady {
+					l.ApplyPoolConfig()
+				}
+			})
+		}
+	})
+}
+
+// Get the statistics of the connection pool
+func (l LibraryGorm) Stats() (sql.DBStats, error) {
+	sqlDB, err := Database[LibraryGorm]{}.Get().DB.DB()
+	if err != nil {
+		return sql.DBStats{}, err
+	}
+	return sqlDB.Stats(), nil
+}
@@ -0,0 +1,71 @@
+package d
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+type PostgreSQL struct{}
+
+// Build the DSN, the host can contain the port, such as 127.0.0.1:5432
+func (p PostgreSQL) Dsn(host, name, user, password string) string {
+	port := ""
+	if h, pt, err := net.SplitHostPort(host); err == nil {
+		host, port = h, pt
+	}
+
+	var dsn []string
+	for _, v := range [][2]string{{"host", host}, {"port", port}, {"user", user}, {"password", password}, {"dbname", name}} {
+		if v[1] == "" {
+			continue
+		}
+		dsn = append(dsn, v[0]+"="+p.quote(v[1]))
+	}
+	return strings.Join(dsn, " ")
+}
+
+func (p PostgreSQL) Open(dsn string) gorm.Dialector {
+	return postgres.Open(dsn)
+}
+
+// Generate case-insensitive lazy query parameters based on parameters and value
+// Example : GenerateFuzzyQueries(map[string]string{"name": "John", "sex": "female"})
+func (p PostgreSQL) GenerateFuzzyQueries(fields map[string]string) (whereClause string, args []interface{}, err error) {
+	return generateFuzzyQueries(fields, `"`, `"`, "ILIKE", DatabaseDriverPostgreSQL)
+}
+
+// Quote the keyword value of the DSN
+func (p PostgreSQL) quote(value string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(value) + "'"
+}
+
+// Take an advisory lock for the session of the connection, waits until it is available
+func (p PostgreSQL) Lock(ctx context.Context, conn *sql.Conn, name string) error {
+	_, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock(hashtext($1))", name)
+	return err
+}
+
+// Release the advisory lock of the session of the connection
+func (p PostgreSQL) Unlock(ctx context.Context, conn *sql.Conn, name string) error {
+	var released bool
+	if err := conn.QueryRowContext(ctx, "SELECT pg_advisory_unlock(hashtext($1))", name).Scan(&released); err != nil {
+		return err
+	}
+	if !released {
+		return errors.New("failed to release lock " + name)
+	}
+	return nil
+}
+
+// Deadlock (40P01) and serialization failure (40001) can succeed when the transaction is retried
+func (p PostgreSQL) IsRetryableError(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && (pgErr.Code == "40P01" || pgErr.Code == "40001")
+}
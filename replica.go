@@ -0,0 +1,249 @@
+package d
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"math/rand"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
+)
+
+var (
+	databaseReplicas      []*replicaConnPool // Replicas of the registered database, used to re-apply the pool config
+	databaseReplicasMutex sync.RWMutex
+)
+
+// Force the query to use the primary, reads are routed to the replicas by default
+// Example: d.Database[d.LibraryGorm]{}.Get().Scopes(d.LibraryGorm{}.UsePrimary()).First(&user)
+func (l LibraryGorm) UsePrimary() func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Clauses(dbresolver.Write)
+	}
+}
+
+// Route reads to the replicas in database.replicas, writes and transactions use the primary
+// A replica that is down is skipped, and the primary is used when no replica is available
+func (l LibraryGorm) useReplicas(db *gorm.DB, dialect InterfaceDialect) error {
+	hosts := configStringSlice(ConfigPathDatabaseReplicas)
+	if len(hosts) == 0 {
+		return nil
+	}
+
+	dbName := Config[InterfaceConfig]{}.Get().GetStringWithDefault(ConfigPathDatabaseName, "")
+	dbUser := Config[InterfaceConfig]{}.Get().GetStringWithDefault(ConfigPathDatabaseUser, "")
+	dbPassword := Config[InterfaceConfig]{}.Get().GetStringWithDefault(ConfigPathDatabasePassword, "")
+	seconds := Config[InterfaceConfig]{}.Get().GetIntWithDefault(ConfigPathDatabaseReplicaCheckInterval, DefaultDatabaseReplicaCheckInterval)
+	if seconds <= 0 {
+		seconds = DefaultDatabaseReplicaCheckInterval
+	}
+	interval := time.Second * time.Duration(seconds)
+
+	var replicas []*replicaConnPool
+	var dialectors []gorm.Dialector
+	for _, host := range hosts {
+		dsn := dialect.Dsn(host, dbName, dbUser, dbPassword)
+		r := &replicaConnPool{
+			primary:  db.ConnPool,
+			interval: interval,
+			open: func() (*sql.DB, error) {
+				replica, err := l.Open(dialect.Open(dsn), &gorm.Config{})
+				if err != nil {
+					return nil, err
+				}
+				sqlDB, err := replica.DB()
+				if err != nil {
+					return nil, err
+				}
+				l.applyPoolConfig(sqlDB)
+				return sqlDB, nil
+			},
+		}
+		if sqlDB, err := r.open(); err == nil {
+			r.db.Store(sqlDB)
+			r.healthy.Store(true)
+		} else {
+			r.markDown()
+		}
+		replicas = append(replicas, r)
+		dialectors = append(dialectors, replicaDialector{Dialector: dialect.Open(dsn), pool: r})
+	}
+
+	err := db.Use(dbresolver.Register(dbresolver.Config{
+		Replicas: dialectors,
+		Policy:   replicaPolicy{},
+	}))
+	if err != nil {
+		for _, r := range replicas {
+			if sqlDB := r.db.Load(); sqlDB != nil {
+				_ = sqlDB.Close()
+			}
+		}
+		return err
+	}
+
+	databaseReplicasMutex.Lock()
+	databaseReplicas = replicas
+	databaseReplicasMutex.Unlock()
+	return nil
+}
+
+// Read a list of the config, by InterfaceConfigStringSlice if the config implements it, otherwise as a comma-separated string
+func configStringSlice(key string) []string {
+	conf := Config[InterfaceConfig]{}.Get()
+	if c, ok := conf.(InterfaceConfigStringSlice); ok {
+		return c.GetStringSlice(key)
+	}
+	var list []string
+	for _, v := range strings.Split(conf.GetStringWithDefault(key, ""), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+// Dialector handed to dbresolver, it only provides the connection pool of the replica
+type replicaDialector struct {
+	gorm.Dialector
+	pool *replicaConnPool
+}
+
+func (d replicaDialector) Initialize(db *gorm.DB) error {
+	db.ConnPool = d.pool
+	return nil
+}
+
+// Pick a random replica that is up, if none is up, the picked one falls back to the primary
+type replicaPolicy struct{}
+
+func (p replicaPolicy) Resolve(pools []gorm.ConnPool) gorm.ConnPool {
+	start := rand.Intn(len(pools))
+	for i := range pools {
+		pool := pools[(start+i)%len(pools)]
+		if r, ok := pool.(*replicaConnPool); ok && r.healthy.Load() {
+			return pool
+		}
+	}
+	return pools[start]
+}
+
+// Connection pool of a replica, queries fail over to the primary while the replica is down
+type replicaConnPool struct {
+	db       atomic.Pointer[sql.DB]
+	open     func() (*sql.DB, error)
+	primary  gorm.ConnPool
+	healthy  atomic.Bool
+	checking atomic.Bool
+	interval time.Duration
+}
+
+func (r *replicaConnPool) pool() gorm.ConnPool {
+	if db := r.db.Load(); db != nil && r.healthy.Load() {
+		return db
+	}
+	return r.primary
+}
+
+func (r *replicaConnPool) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	pool := r.pool()
+	stmt, err := pool.PrepareContext(ctx, query)
+	if r.failed(pool, err) {
+		return r.primary.PrepareContext(ctx, query)
+	}
+	return stmt, err
+}
+
+func (r *replicaConnPool) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	pool := r.pool()
+	result, err := pool.ExecContext(ctx, query, args...)
+	if r.failed(pool, err) {
+		return r.primary.ExecContext(ctx, query, args...)
+	}
+	return result, err
+}
+
+func (r *replicaConnPool) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	pool := r.pool()
+	rows, err := pool.QueryContext(ctx, query, args...)
+	if r.failed(pool, err) {
+		return r.primary.QueryContext(ctx, query, args...)
+	}
+	return rows, err
+}
+
+func (r *replicaConnPool) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	pool := r.pool()
+	row := pool.QueryRowContext(ctx, query, args...)
+	if r.failed(pool, row.Err()) {
+		return r.primary.QueryRowContext(ctx, query, args...)
+	}
+	return row
+}
+
+// Begin a transaction on the replica, or on the primary while the replica is down
+func (r *replicaConnPool) BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error) {
+	pool := r.pool()
+	beginner, ok := pool.(gorm.TxBeginner)
+	if !ok {
+		return nil, gorm.ErrInvalidTransaction
+	}
+	tx, err := beginner.BeginTx(ctx, opts)
+	if r.failed(pool, err) {
+		if beginner, ok = r.primary.(gorm.TxBeginner); !ok {
+			return nil, gorm.ErrInvalidTransaction
+		}
+		return beginner.BeginTx(ctx, opts)
+	}
+	return tx, err
+}
+
+// Whether the query failed because the replica is unreachable, the replica is marked as down if so
+func (r *replicaConnPool) failed(pool gorm.ConnPool, err error) bool {
+	if err == nil || pool == r.primary {
+		return false
+	}
+	var netErr net.Error
+	if !errors.Is(err, driver.ErrBadConn) && !errors.As(err, &netErr) {
+		return false
+	}
+	r.markDown()
+	return true
+}
+
+// Mark the replica as down and check it in the background until it is up again
+func (r *replicaConnPool) markDown() {
+	r.healthy.Store(false)
+	if !r.checking.CompareAndSwap(false, true) {
+		return
+	}
+	go func() {
+		defer r.checking.Store(false)
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			db := r.db.Load()
+			if db == nil {
+				var err error
+				if db, err = r.open(); err != nil {
+					continue
+				}
+				r.db.Store(db)
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), r.interval)
+			err := db.PingContext(ctx)
+			cancel()
+			if err == nil {
+				r.healthy.Store(true)
+				return
+			}
+		}
+	}()
+}
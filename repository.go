@@ -0,0 +1,125 @@
+package d
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"reflect"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+var (
+	ErrRepositoryNoSoftDelete = errors.New("model has no gorm.DeletedAt field")
+)
+
+// Generic repository of a GORM model
+// Example:
+// users := d.Repository[User]{}
+// user, err := users.FindByID(ctx, 1, "Roles")
+type Repository[M any] struct {
+	DB *gorm.DB // If nil, the transaction carried by the context or the registered database is used
+}
+
+func (r Repository[M]) db(ctx context.Context) *gorm.DB {
+	if r.DB != nil {
+		return r.DB.WithContext(ctx)
+	}
+	return LibraryGorm{}.FromContext(ctx)
+}
+
+// Condition of the primary key
+func (r Repository[M]) primaryKey(id interface{}) clause.Expression {
+	return clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: clause.PrimaryKey}, Value: id}
+}
+
+// Find by primary key, returns gorm.ErrRecordNotFound if there is no record
+func (r Repository[M]) FindByID(ctx context.Context, id interface{}, preloads ...string) (m M, err error) {
+	tx := r.db(ctx).Model(&m)
+	for _, v := range preloads {
+		tx = tx.Preload(v)
+	}
+	err = tx.Where(r.primaryKey(id)).First(&m).Error
+	return m, err
+}
+
+// Find a page with the fuzzy, filter and sort query of the values, the list of the pagination is *[]M
+// Example:
+// p, err := users.FindMany(ctx, c.Request.URL.Query(), d.ListQuery{FuzzyFields: []string{"name"}, SortFields: []string{"created_at"}})
+func (r Repository[M]) FindMany(ctx context.Context, values url.Values, q ListQuery, scopes ...func(*gorm.DB) *gorm.DB) (InterfacePagination, error) {
+	var list []M
+	return LibraryGorm{}.GetList(r.db(ctx).Model(new(M)).Scopes(scopes...), values, q, &list)
+}
+
+// Create a record, the primary key is set on m
+func (r Repository[M]) Create(ctx context.Context, m *M) error {
+	return r.db(ctx).Create(m).Error
+}
+
+// Update the record of the primary key of m
+// Only the fields in the mask are updated, including zero values, if the mask is empty, the non-zero fields are updated
+// Example: users.Update(ctx, &user, "name", "email")
+func (r Repository[M]) Update(ctx context.Context, m *M, fields ...string) error {
+	tx := r.db(ctx).Model(m)
+	if len(fields) > 0 {
+		tx = tx.Select(fields)
+	}
+	return tx.Updates(m).Error
+}
+
+// Update the record of the primary key id, the primary key of m cannot select another record
+// Only the fields in the mask are updated, including zero values, if the mask is empty, the non-zero fields are updated
+func (r Repository[M]) UpdateByID(ctx context.Context, id interface{}, m *M, fields ...string) error {
+	tx := r.db(ctx).Model(m).Where(r.primaryKey(id))
+	if len(fields) > 0 {
+		tx = tx.Select(fields)
+	}
+	return tx.Updates(m).Error
+}
+
+// Delete the record permanently, even if the model supports soft delete
+func (r Repository[M]) Delete(ctx context.Context, id interface{}) error {
+	return r.db(ctx).Unscoped().Where(r.primaryKey(id)).Delete(new(M)).Error
+}
+
+// Soft delete the record, the model must have a gorm.DeletedAt field
+func (r Repository[M]) SoftDelete(ctx context.Context, id interface{}) error {
+	tx := r.db(ctx)
+	if _, err := r.deletedAtField(tx); err != nil {
+		return err
+	}
+	return tx.Where(r.primaryKey(id)).Delete(new(M)).Error
+}
+
+// Restore a soft deleted record
+func (r Repository[M]) Restore(ctx context.Context, id interface{}) error {
+	tx := r.db(ctx)
+	field, err := r.deletedAtField(tx)
+	if err != nil {
+		return err
+	}
+	return tx.Unscoped().Model(new(M)).Where(r.primaryKey(id)).Update(field.DBName, nil).Error
+}
+
+// Whether a record matches the conditions, soft deleted records are excluded
+// Example: users.Exists(ctx, "email = ?", email)
+func (r Repository[M]) Exists(ctx context.Context, query interface{}, args ...interface{}) (bool, error) {
+	var found []int
+	result := r.db(ctx).Model(new(M)).Select("1").Where(query, args...).Limit(1).Find(&found)
+	return result.RowsAffected > 0, result.Error
+}
+
+func (r Repository[M]) deletedAtField(tx *gorm.DB) (*schema.Field, error) {
+	stmt := &gorm.Statement{DB: tx}
+	if err := stmt.Parse(new(M)); err != nil {
+		return nil, err
+	}
+	for _, field := range stmt.Schema.Fields {
+		if field.FieldType == reflect.TypeOf(gorm.DeletedAt{}) {
+			return field, nil
+		}
+	}
+	return nil, ErrRepositoryNoSoftDelete
+}
@@ -0,0 +1,331 @@
+package d
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// Action of the resource routes
+type ResourceAction string
+
+const (
+	ResourceActionList   ResourceAction = "list"
+	ResourceActionGet    ResourceAction = "get"
+	ResourceActionCreate ResourceAction = "create"
+	ResourceActionUpdate ResourceAction = "update"
+	ResourceActionDelete ResourceAction = "delete"
+)
+
+var (
+	ErrResourceApiNotSupported = errors.New("the api does not implement InterfaceApiData")
+)
+
+// Options of the resource routes of model M
+type ResourceOptions[M any] struct {
+	Api        InterfaceApi                                            // Must implement InterfaceApiData, if nil, the initialized Api is used
+	Actions    []ResourceAction                                        // The routes to register, if empty, all routes are registered
+	ListQuery  ListQuery                                               // Fuzzy, filter and sort fields of the list route
+	Preloads   []string                                                // Associations preloaded by the list and get routes
+	BindCreate func(c *gin.Context, m *M) error                        // Bind the input of the create route, default binds the JSON body to M except the fields managed by the database
+	BindUpdate func(c *gin.Context, m *M) (fields []string, err error) // Bind the input of the update route and return the updated columns, default is the keys of the JSON body, the primary key is kept
+	Authorize  func(c *gin.Context, action ResourceAction) error       // Called before every action, an error rejects the request
+	Before     func(c *gin.Context, action ResourceAction, m *M) error // Called after Authorize, m is nil for list and get
+	After      func(c *gin.Context, action ResourceAction, m *M)       // Called after the action succeeds, m is nil for list
+}
+
+// Register the list, get, create, update and delete routes of model M
+// GET path, GET path/:id, POST path, PUT path/:id, PATCH path/:id, DELETE path/:id
+// Example:
+//
+//	d.RegisterResource(router.Group("/api"), "/users", d.ResourceOptions[User]{
+//		ListQuery: d.ListQuery{FuzzyFields: []string{"name"}, SortFields: []string{"created_at"}},
+//		Preloads:  []string{"Roles"},
+//	})
+func RegisterResource[M any](r *gin.RouterGroup, path string, opt ResourceOptions[M]) {
+	if opt.Api == nil {
+		opt.Api = Api[InterfaceApi]{}.Get()
+	}
+	if _, ok := opt.Api.(InterfaceApiData); !ok {
+		panic(ErrResourceApiNotSupported)
+	}
+	if opt.BindCreate == nil {
+		opt.BindCreate = bindResourceCreate[M]
+	}
+	if opt.BindUpdate == nil {
+		opt.BindUpdate = bindResourceUpdate[M]
+	}
+	if len(opt.Actions) == 0 {
+		opt.Actions = []ResourceAction{ResourceActionList, ResourceActionGet, ResourceActionCreate, ResourceActionUpdate, ResourceActionDelete}
+	}
+
+	res := resource[M]{opt: opt}
+	path = strings.TrimSuffix(path, "/")
+	for _, v := range opt.Actions {
+		switch v {
+		case ResourceActionList:
+			r.GET(path, res.list)
+		case ResourceActionGet:
+			r.GET(path+"/:id", res.get)
+		case ResourceActionCreate:
+			r.POST(path, res.create)
+		case ResourceActionUpdate:
+			r.PUT(path+"/:id", res.update)
+			r.PATCH(path+"/:id", res.update)
+		case ResourceActionDelete:
+			r.DELETE(path+"/:id", res.delete)
+		}
+	}
+}
+
+type resource[M any] struct {
+	opt  ResourceOptions[M]
+	repo Repository[M]
+}
+
+func (res resource[M]) list(c *gin.Context) {
+	if !res.authorize(c, ResourceActionList) || !res.before(c, ResourceActionList, nil) {
+		return
+	}
+	preloads := func(db *gorm.DB) *gorm.DB {
+		for _, v := range res.opt.Preloads {
+			db = db.Preload(v)
+		}
+		return db
+	}
+	p, err := res.repo.FindMany(c.Request.Context(), c.Request.URL.Query(), res.opt.ListQuery, preloads)
+	if err != nil {
+		res.error(c, err)
+		return
+	}
+	res.after(c, ResourceActionList, nil)
+	Gin{}.Pagination(c, res.opt.Api, p)
+}
+
+func (res resource[M]) get(c *gin.Context) {
+	if !res.authorize(c, ResourceActionGet) || !res.before(c, ResourceActionGet, nil) {
+		return
+	}
+	m, err := res.repo.FindByID(c.Request.Context(), c.Param("id"), res.opt.Preloads...)
+	if err != nil {
+		res.error(c, err)
+		return
+	}
+	res.after(c, ResourceActionGet, &m)
+	res.success(c, m)
+}
+
+func (res resource[M]) create(c *gin.Context) {
+	if !res.authorize(c, ResourceActionCreate) {
+		return
+	}
+	var m M
+	if err := res.opt.BindCreate(c, &m); err != nil {
+		res.error(c, err)
+		return
+	}
+	if !res.before(c, ResourceActionCreate, &m) {
+		return
+	}
+	if err := res.repo.Create(c.Request.Context(), &m); err != nil {
+		res.error(c, err)
+		return
+	}
+	res.after(c, ResourceActionCreate, &m)
+	res.success(c, m)
+}
+
+func (res resource[M]) update(c *gin.Context) {
+	if !res.authorize(c, ResourceActionUpdate) {
+		return
+	}
+	ctx := c.Request.Context()
+	m, err := res.repo.FindByID(ctx, c.Param("id"))
+	if err != nil {
+		res.error(c, err)
+		return
+	}
+	loaded := m
+	fields, err := res.opt.BindUpdate(c, &m)
+	if err != nil {
+		res.error(c, err)
+		return
+	}
+	// The body cannot change the primary key, which would update another record
+	if err = restoreResourcePrimaryKey(&m, loaded); err != nil {
+		res.error(c, err)
+		return
+	}
+	if !res.before(c, ResourceActionUpdate, &m) {
+		return
+	}
+	if len(fields) > 0 {
+		if err = res.repo.UpdateByID(ctx, c.Param("id"), &m, fields...); err != nil {
+			res.error(c, err)
+			return
+		}
+	}
+	if m, err = res.repo.FindByID(ctx, c.Param("id"), res.opt.Preloads...); err != nil {
+		res.error(c, err)
+		return
+	}
+	res.after(c, ResourceActionUpdate, &m)
+	res.success(c, m)
+}
+
+func (res resource[M]) delete(c *gin.Context) {
+	if !res.authorize(c, ResourceActionDelete) {
+		return
+	}
+	ctx := c.Request.Context()
+	m, err := res.repo.FindByID(ctx, c.Param("id"))
+	if err != nil {
+		res.error(c, err)
+		return
+	}
+	if !res.before(c, ResourceActionDelete, &m) {
+		return
+	}
+	// Soft delete if the model supports it
+	err = res.repo.SoftDelete(ctx, c.Param("id"))
+	if errors.Is(err, ErrRepositoryNoSoftDelete) {
+		err = res.repo.Delete(ctx, c.Param("id"))
+	}
+	if err != nil {
+		res.error(c, err)
+		return
+	}
+	res.after(c, ResourceActionDelete, &m)
+	res.success(c, nil)
+}
+
+// Call the Authorize callback, responds with the error and returns false if it fails
+func (res resource[M]) authorize(c *gin.Context, action ResourceAction) bool {
+	if res.opt.Authorize != nil {
+		if err := res.opt.Authorize(c, action); err != nil {
+			res.error(c, err)
+			return false
+		}
+	}
+	return true
+}
+
+// Call the Before hook, responds with the error and returns false if it fails
+func (res resource[M]) before(c *gin.Context, action ResourceAction, m *M) bool {
+	if res.opt.Before != nil {
+		if err := res.opt.Before(c, action, m); err != nil {
+			res.error(c, err)
+			return false
+		}
+	}
+	return true
+}
+
+func (res resource[M]) after(c *gin.Context, action ResourceAction, m *M) {
+	if res.opt.After != nil {
+		res.opt.After(c, action, m)
+	}
+}
+
+func (res resource[M]) success(c *gin.Context, data interface{}) {
+	Gin{}.Success(c, res.opt.Api.(InterfaceApiData).WithData(data))
+}
+
+func (res resource[M]) error(c *gin.Context, err error) {
+	Gin{}.ErrorFrom(c, res.opt.Api, err)
+}
+
+// Bind the JSON body to m, the fields managed by the database are reset, such as the auto increment primary key and the timestamps
+func bindResourceCreate[M any](c *gin.Context, m *M) error {
+	if err := (Gin{}).ShouldBindJSON(c, m); err != nil {
+		return err
+	}
+	s, err := parseResourceSchema(m)
+	if err != nil {
+		return err
+	}
+	rv := reflect.ValueOf(m).Elem()
+	for _, field := range s.Fields {
+		if resourceManagedField(field) {
+			field.ReflectValueOf(c.Request.Context(), rv).Set(reflect.Zero(field.FieldType))
+		}
+	}
+	return nil
+}
+
+// Bind the JSON body to m and validate it, the updated columns are the keys of the body that match the JSON names of the fields
+// The primary key and the fields managed by the database are not updated
+func bindResourceUpdate[M any](c *gin.Context, m *M) (fields []string, err error) {
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		return nil, err
+	}
+	var keys map[string]json.RawMessage
+	if err = json.Unmarshal(body, &keys); err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(body, m); err != nil {
+		return nil, err
+	}
+	if err = binding.Validator.ValidateStruct(m); err != nil {
+		if apiErr := NewValidationApiError(err, m, requestLanguage(c)); apiErr != nil {
+			return nil, apiErr
+		}
+		return nil, err
+	}
+
+	s, err := parseResourceSchema(m)
+	if err != nil {
+		return nil, err
+	}
+	for _, field := range s.Fields {
+		if field.DBName == "" || field.PrimaryKey || !field.Updatable || resourceManagedField(field) {
+			continue
+		}
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "" {
+			name = field.Name
+		}
+		if _, ok := keys[name]; ok && name != "-" {
+			fields = append(fields, field.DBName)
+		}
+	}
+	return fields, nil
+}
+
+// Restore the primary key of m from the loaded record
+func restoreResourcePrimaryKey[M any](m *M, loaded M) error {
+	s, err := parseResourceSchema(m)
+	if err != nil {
+		return err
+	}
+	rv, lv := reflect.ValueOf(m).Elem(), reflect.ValueOf(&loaded).Elem()
+	for _, field := range s.PrimaryFields {
+		field.ReflectValueOf(context.Background(), rv).Set(field.ReflectValueOf(context.Background(), lv))
+	}
+	return nil
+}
+
+func parseResourceSchema(m interface{}) (*schema.Schema, error) {
+	stmt := &gorm.Statement{DB: Database[LibraryGorm]{}.Get().DB}
+	if err := stmt.Parse(m); err != nil {
+		return nil, err
+	}
+	return stmt.Schema, nil
+}
+
+// Whether the field is set by the database or by GORM rather than by the client
+func resourceManagedField(field *schema.Field) bool {
+	if field.PrimaryKey {
+		return field.AutoIncrement || field.HasDefaultValue
+	}
+	return field.AutoCreateTime > 0 || field.AutoUpdateTime > 0 || field.FieldType == reflect.TypeOf(gorm.DeletedAt{})
+}
@@ -0,0 +1,102 @@
+package d
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+type testNote struct {
+	ID        uint      `json:"id"`
+	Text      string    `json:"text" binding:"max=10"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Register the resource routes of testNote on an in-memory SQLite database with two notes
+func newTestResource(t *testing.T) (*gin.Engine, *gorm.DB) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	initTestConfig(t, `api: {}`)
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = db.AutoMigrate(&testNote{}); err != nil {
+		t.Fatal(err)
+	}
+	if err = db.Create(&[]testNote{{Text: "first"}, {Text: "second"}}).Error; err != nil {
+		t.Fatal(err)
+	}
+	Database[LibraryGorm]{}.Init(LibraryGorm{DB: db})
+
+	router := gin.New()
+	RegisterResource(router.Group(""), "/notes", ResourceOptions[testNote]{Api: LibraryApi{}})
+	return router, db
+}
+
+func serveTestResource(router *gin.Engine, method, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestResourceUpdateKeepsPrimaryKey(t *testing.T) {
+	router, db := newTestResource(t)
+
+	for _, method := range []string{http.MethodPut, http.MethodPatch} {
+		w := serveTestResource(router, method, "/notes/1", `{"id":2,"text":"changed"}`)
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s status = %d, body %s", method, w.Code, w.Body)
+		}
+		var notes []testNote
+		if err := db.Order("id").Find(&notes).Error; err != nil {
+			t.Fatal(err)
+		}
+		if len(notes) != 2 || notes[0].Text != "changed" || notes[1].Text != "second" {
+			t.Errorf("%s /notes/1 with the id 2 in the body updated the notes to %+v", method, notes)
+		}
+	}
+}
+
+func TestResourceUpdateValidates(t *testing.T) {
+	router, db := newTestResource(t)
+
+	w := serveTestResource(router, http.MethodPatch, "/notes/1", `{"text":"longer than ten"}`)
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("status = %d, want %d, body %s", w.Code, http.StatusUnprocessableEntity, w.Body)
+	}
+	var note testNote
+	if err := db.First(&note, 1).Error; err != nil {
+		t.Fatal(err)
+	}
+	if note.Text != "first" {
+		t.Errorf("an invalid body updated the text to %q", note.Text)
+	}
+}
+
+func TestResourceCreateIgnoresManagedFields(t *testing.T) {
+	router, db := newTestResource(t)
+
+	w := serveTestResource(router, http.MethodPost, "/notes", `{"id":1,"text":"third","created_at":"2000-01-01T00:00:00Z"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", w.Code, w.Body)
+	}
+	var notes []testNote
+	if err := db.Order("id").Find(&notes).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(notes) != 3 || notes[0].Text != "first" {
+		t.Fatalf("the create overwrote a note, the notes are %+v", notes)
+	}
+	if notes[2].ID != 3 || notes[2].CreatedAt.Year() == 2000 {
+		t.Errorf("the id and the creation time of the body are kept, the note is %+v", notes[2])
+	}
+}
@@ -0,0 +1,226 @@
+package d
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// Seed data of a model, the rows are upserted by the natural key, a unique index on the key columns is required
+// Example:
+// d.Seed{Keys: []string{"code"}, Data: []Role{{Code: "admin", Name: "Administrator"}}}
+type Seed struct {
+	Name  string      // Unique name of the seed, default is the table name of the model
+	Keys  []string    // Natural key columns, such as code or email
+	Data  interface{} // Slice of models
+	Force bool        // Upsert even if the checksum has not changed
+}
+
+// Result of a seed
+type SeedResult struct {
+	Name      string
+	Inserted  int
+	Updated   int
+	Revived   int // Soft-deleted rows restored by the seed
+	Unchanged int
+	Skipped   bool // The checksum has not changed since the last seeding
+}
+
+// Checksum of the seeded data, used to skip seeds that have not changed
+type SeedChecksum struct {
+	Name     string `gorm:"primaryKey;size:191"`
+	Checksum string `gorm:"size:64"`
+	SeededAt time.Time
+}
+
+func (SeedChecksum) TableName() string {
+	return SeedChecksumTableName
+}
+
+var (
+	SeedChecksumTableName = "seed_checksums"
+)
+
+var (
+	ErrSeedNoKeys       = errors.New("seed requires at least one natural key column")
+	ErrSeedInvalidData  = errors.New("seed data must be a slice of models")
+	ErrSeedUnknownField = errors.New("seed key column does not exist in the model")
+)
+
+// Upsert the seed data by natural key in one transaction, the checksums are recorded in the database
+// Example:
+// results, err := d.LibraryGorm{}.Seed(ctx, d.Seed{Keys: []string{"code"}, Data: roles})
+func (l LibraryGorm) Seed(ctx context.Context, seeds ...Seed) (results []SeedResult, err error) {
+	db := Database[LibraryGorm]{}.Get().DB.WithContext(ctx)
+	if err = db.AutoMigrate(&SeedChecksum{}); err != nil {
+		return nil, err
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		for _, v := range seeds {
+			result, err := l.seed(tx, v)
+			if err != nil {
+				return fmt.Errorf("seed %s: %w", result.Name, err)
+			}
+			results = append(results, result)
+		}
+		return nil
+	})
+	return results, err
+}
+
+func (l LibraryGorm) seed(tx *gorm.DB, s Seed) (result SeedResult, err error) {
+	result.Name = s.Name
+	list := reflect.Indirect(reflect.ValueOf(s.Data))
+	if list.Kind() != reflect.Slice {
+		return result, ErrSeedInvalidData
+	}
+	if len(s.Keys) == 0 {
+		return result, ErrSeedNoKeys
+	}
+
+	elemType := list.Type().Elem()
+	for elemType.Kind() == reflect.Ptr {
+		elemType = elemType.Elem()
+	}
+	stmt := &gorm.Statement{DB: tx}
+	if err = stmt.Parse(reflect.New(elemType).Interface()); err != nil {
+		return result, err
+	}
+	if result.Name == "" {
+		result.Name = stmt.Schema.Table
+	}
+
+	b, err := json.Marshal(s.Data)
+	if err != nil {
+		return result, err
+	}
+	sum := sha256.Sum256(b)
+	checksum := hex.EncodeToString(sum[:])
+
+	var record SeedChecksum
+	if err = tx.Where("name = ?", result.Name).Limit(1).Find(&record).Error; err != nil {
+		return result, err
+	}
+	if !s.Force && record.Checksum == checksum {
+		result.Unchanged, result.Skipped = list.Len(), true
+		return result, nil
+	}
+
+	var keys []*schema.Field
+	for _, v := range s.Keys {
+		field := stmt.Schema.LookUpField(v)
+		if field == nil || field.DBName == "" {
+			return result, fmt.Errorf("%w: %s", ErrSeedUnknownField, v)
+		}
+		keys = append(keys, field)
+	}
+	columns := l.seedUpdateColumns(stmt.Schema, keys)
+
+	var conflict []clause.Column
+	for _, v := range keys {
+		conflict = append(conflict, clause.Column{Name: v.DBName})
+	}
+	onConflict := clause.OnConflict{Columns: conflict, DoUpdates: clause.AssignmentColumns(columns)}
+	// Without columns to update, the existing rows are kept as they are
+	if len(columns) == 0 {
+		onConflict = clause.OnConflict{Columns: conflict, DoNothing: true}
+	}
+
+	for i := 0; i < list.Len(); i++ {
+		row := list.Index(i)
+		if row.Kind() != reflect.Ptr {
+			row = row.Addr()
+		}
+
+		changed, exists, revived, err := l.seedCompare(tx, stmt.Schema, keys, columns, row)
+		if err != nil {
+			return result, err
+		}
+		if exists && !changed {
+			result.Unchanged++
+			continue
+		}
+
+		created := tx.Clauses(onConflict).Create(row.Interface())
+		if created.Error != nil {
+			return result, created.Error
+		}
+		switch {
+		case created.RowsAffected == 0:
+			// Nothing was written, such as DO NOTHING on a conflict
+			result.Unchanged++
+		case !exists:
+			result.Inserted++
+		case revived:
+			result.Revived++
+		default:
+			result.Updated++
+		}
+	}
+
+	record = SeedChecksum{Name: result.Name, Checksum: checksum, SeededAt: time.Now()}
+	err = tx.Clauses(clause.OnConflict{UpdateAll: true}).Create(&record).Error
+	return result, err
+}
+
+// The columns updated on conflict, the primary key, natural key and creation time are kept
+func (l LibraryGorm) seedUpdateColumns(s *schema.Schema, keys []*schema.Field) (columns []string) {
+	for _, field := range s.Fields {
+		if field.DBName == "" || field.PrimaryKey || field.AutoCreateTime > 0 || !field.Updatable {
+			continue
+		}
+		isKey := false
+		for _, k := range keys {
+			isKey = isKey || k == field
+		}
+		if !isKey {
+			columns = append(columns, field.DBName)
+		}
+	}
+	return columns
+}
+
+// Find the existing row by the natural key and compare the updated columns
+// Soft-deleted rows are found as well, as they still hold the natural key, revived is whether the update restores the row
+func (l LibraryGorm) seedCompare(tx *gorm.DB, s *schema.Schema, keys []*schema.Field, columns []string, row reflect.Value) (changed, exists, revived bool, err error) {
+	ctx := tx.Statement.Context
+	conditions := map[string]interface{}{}
+	for _, v := range keys {
+		conditions[v.DBName], _ = v.ValueOf(ctx, row.Elem())
+	}
+
+	existing := reflect.New(row.Elem().Type())
+	result := tx.Unscoped().Model(existing.Interface()).Where(conditions).Limit(1).Find(existing.Interface())
+	if result.Error != nil || result.RowsAffected == 0 {
+		return false, false, false, result.Error
+	}
+
+	for _, v := range columns {
+		field := s.LookUpField(v)
+		// The update time always differs and does not tell whether the data changed
+		if field.AutoUpdateTime > 0 {
+			continue
+		}
+		a, _ := field.ValueOf(ctx, row.Elem())
+		b, _ := field.ValueOf(ctx, existing.Elem())
+		if reflect.DeepEqual(a, b) {
+			continue
+		}
+		changed = true
+		if deleted, ok := b.(gorm.DeletedAt); ok && deleted.Valid {
+			restored, _ := a.(gorm.DeletedAt)
+			revived = !restored.Valid
+		}
+	}
+	return changed, true, revived, nil
+}
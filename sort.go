@@ -2,7 +2,13 @@ package d
 
 import (
 	"errors"
+	"fmt"
+	"slices"
 	"sort"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type InterfaceSort interface {
@@ -26,3 +32,67 @@ func SortListWithChildrenBySortField[T InterfaceSort](list []T) error {
 	}
 	return nil
 }
+
+// Column of the sort query, such as ?sort=-created_at,name
+type SortColumn struct {
+	Name string
+	Desc bool // Prefixed with -
+}
+
+var (
+	ErrSortFieldNotAllowed = errors.New("sort field is not allowed")
+)
+
+// Error of a single sort column, use errors.Is to check the reason
+type SortError struct {
+	Field string
+	Err   error
+}
+
+func (e *SortError) Error() string {
+	return fmt.Sprintf("sort %q: %v", e.Field, e.Err)
+}
+
+func (e *SortError) Unwrap() error {
+	return e.Err
+}
+
+// Parse the sort query, the columns must be in allowed
+// Example: ParseSortQuery("-created_at,name", []string{"created_at", "name"})
+func ParseSortQuery(value string, allowed []string) (columns []SortColumn, err error) {
+	for _, v := range strings.Split(value, ",") {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+		column := SortColumn{Name: strings.TrimPrefix(v, "-"), Desc: strings.HasPrefix(v, "-")}
+		if !slices.Contains(allowed, column.Name) || !regexpColumnIdentifier.MatchString(column.Name) {
+			return nil, &SortError{Field: column.Name, Err: ErrSortFieldNotAllowed}
+		}
+		columns = append(columns, column)
+	}
+	return columns, nil
+}
+
+// Format the sort columns back to the query format
+func FormatSortQuery(columns []SortColumn) string {
+	var list []string
+	for _, v := range columns {
+		if v.Desc {
+			list = append(list, "-"+v.Name)
+		} else {
+			list = append(list, v.Name)
+		}
+	}
+	return strings.Join(list, ",")
+}
+
+// Apply the sort columns in order
+func (l LibraryGorm) Sort(columns []SortColumn) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		for _, v := range columns {
+			db = db.Order(clause.OrderByColumn{Column: clause.Column{Name: v.Name}, Desc: v.Desc})
+		}
+		return db
+	}
+}
@@ -0,0 +1,23 @@
+package d
+
+import (
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+type SQLite struct{}
+
+// Build the DSN, database.name is the path of the database file
+func (s SQLite) Dsn(host, name, user, password string) string {
+	return name
+}
+
+func (s SQLite) Open(dsn string) gorm.Dialector {
+	return sqlite.Open(dsn)
+}
+
+// Generate lazy query parameters based on parameters and value
+// Example : GenerateFuzzyQueries(map[string]string{"name": "John", "sex": "female"})
+func (s SQLite) GenerateFuzzyQueries(fields map[string]string) (whereClause string, args []interface{}, err error) {
+	return generateFuzzyQueries(fields, `"`, `"`, "LIKE", DatabaseDriverSQLite)
+}
@@ -0,0 +1,311 @@
+package d
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"reflect"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	MIMEApplicationNDJSON = "application/x-ndjson"
+)
+
+const (
+	ConfigPathStreamFlushRows    = "stream.flush_rows"    // The rows written between two flushes
+	ConfigPathStreamWriteTimeout = "stream.write_timeout" // Seconds, a flush slower than this aborts the stream, 0 means no timeout
+)
+
+var (
+	DefaultStreamFlushRows    = 100
+	DefaultStreamWriteTimeout = 30
+)
+
+var (
+	ErrStreamNotStruct = errors.New("stream requires a pointer to a struct")
+)
+
+// Iterate the rows of the list with fuzzy, filter and sort query one by one, the pagination query is ignored
+// Every row is scanned into data_pointer, a pointer to a struct, before fn is called, the rows are not held in memory
+// The iteration stops when the context of the query is done, such as the client disconnected, or fn returns an error
+// Preloads are not supported, as the rows are read by gorm.DB.Rows
+// Example:
+// err := d.LibraryGorm{}.ForEach(query.WithContext(ctx), r.URL.Query(), d.ListQuery{FuzzyFields: []string{"name"}}, &user, func() error { return enc.Encode(user) })
+func (l LibraryGorm) ForEach(query *gorm.DB, values url.Values, q ListQuery, data_pointer interface{}, fn func() error) error {
+	v := reflect.ValueOf(data_pointer)
+	if v.Kind() != reflect.Pointer || v.Elem().Kind() != reflect.Struct {
+		return ErrStreamNotStruct
+	}
+	if query.Statement.Model == nil {
+		query = query.Model(data_pointer)
+	}
+
+	tx, _, err := l.ApplyListQuery(query, values, q)
+	if err != nil {
+		return err
+	}
+
+	rows, err := tx.Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	ctx := tx.Statement.Context
+	zero := reflect.Zero(v.Elem().Type())
+	for rows.Next() {
+		if err = ctx.Err(); err != nil {
+			return err
+		}
+		// The row does not keep the values of the previous row
+		v.Elem().Set(zero)
+		if err = tx.ScanRows(rows, data_pointer); err != nil {
+			return err
+		}
+		if err = fn(); err != nil {
+			return err
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return err
+	}
+	return ctx.Err()
+}
+
+// Stream the list with fuzzy, filter and sort query, the rows are written as they are read from the database
+// If the Accept header is application/x-ndjson, every row is a line of the success response of the api,
+// otherwise the response is the success response of the api whose data is a JSON array written in chunks
+// The writes block when the client reads slowly, so the database is not read faster than the client, see stream.write_timeout
+// If nothing is written when an error is returned, such as an invalid query, the error can still be responded
+// After the first bytes, an NDJSON stream ends with a line of the error response, a JSON array stream is left incomplete
+// Example:
+//
+//	var user User
+//	if err := d.Gin{}.Stream(c, api, query, d.ListQuery{FuzzyFields: []string{"name"}}, &user); err != nil && !c.Writer.Written() {
+//		d.Gin{}.ErrorFrom(c, api, err)
+//	}
+func (g Gin) Stream(c *gin.Context, a InterfaceApi, query *gorm.DB, q ListQuery, data_pointer interface{}) error {
+	// If gin.Context is nil
+	if c == nil {
+		return errors.New("gin.Context is nil")
+	}
+	ad, ok := g.forRequest(c, a).(InterfaceApiData)
+	if !ok {
+		return ErrResourceApiNotSupported
+	}
+
+	s := &stream{c: c, controller: http.NewResponseController(c.Writer)}
+	defer s.close()
+	s.flushRows = Config[InterfaceConfig]{}.Get().GetIntWithDefault(ConfigPathStreamFlushRows, DefaultStreamFlushRows)
+	s.writeTimeout = time.Duration(Config[InterfaceConfig]{}.Get().GetIntWithDefault(ConfigPathStreamWriteTimeout, DefaultStreamWriteTimeout)) * time.Second
+
+	query = query.WithContext(c.Request.Context())
+	values := c.Request.URL.Query()
+
+	var err error
+	if NegotiateStreamFormat(c.GetHeader("Accept")) == MIMEApplicationNDJSON {
+		err = g.streamNDJSON(s, ad, query, values, q, data_pointer)
+	} else {
+		err = g.streamJSON(s, ad, query, values, q, data_pointer)
+	}
+	if err != nil && c.Writer.Written() {
+		// The response is incomplete, the error is recorded in the gin context
+		_ = c.Error(err)
+	}
+	return err
+}
+
+// Returns application/x-ndjson if it is preferred by the Accept header, otherwise application/json
+func NegotiateStreamFormat(accept string) string {
+	for _, v := range parseAccept(accept) {
+		switch v {
+		case MIMEApplicationNDJSON, "application/jsonl":
+			return MIMEApplicationNDJSON
+		case MIMEApplicationJSON, "*/*", "application/*":
+			return MIMEApplicationJSON
+		}
+	}
+	return MIMEApplicationJSON
+}
+
+func (g Gin) streamNDJSON(s *stream, a InterfaceApiData, query *gorm.DB, values url.Values, q ListQuery, data_pointer interface{}) error {
+	s.contentType = MIMEApplicationNDJSON
+	err := LibraryGorm{}.ForEach(query, values, q, data_pointer, func() error {
+		b, err := json.Marshal(a.WithData(data_pointer).Success())
+		if err != nil {
+			return err
+		}
+		return s.write(append(b, '\n'), true)
+	})
+	if err != nil {
+		if s.c.Writer.Written() && !errors.Is(err, context.Canceled) {
+			// The client is still there, tell it the stream failed
+			if b, e := json.Marshal(a.WithError(err).Error()); e == nil {
+				_ = s.write(append(b, '\n'), false)
+				_ = s.flush()
+			}
+		}
+		return err
+	}
+	// An empty list is an empty body
+	if err = s.write(nil, false); err != nil {
+		return err
+	}
+	return s.flush()
+}
+
+func (g Gin) streamJSON(s *stream, a InterfaceApiData, query *gorm.DB, values url.Values, q ListQuery, data_pointer interface{}) error {
+	s.contentType = "application/json; charset=utf-8"
+
+	// The envelope is split around the data, the rows are written between the two parts
+	prefix, suffix, path, err := splitStreamEnvelope(a)
+	if err != nil {
+		return err
+	}
+
+	r := newApiFieldRenamer()
+	first := true
+	err = LibraryGorm{}.ForEach(query, values, q, data_pointer, func() error {
+		var b []byte
+		var err error
+		if r.empty() {
+			b, err = json.Marshal(data_pointer)
+		} else {
+			var v interface{}
+			if v, err = toJSONValue(data_pointer); err == nil {
+				b, err = json.Marshal(r.renameAt(v, path+"[]"))
+			}
+		}
+		if err != nil {
+			return err
+		}
+
+		if first {
+			b = append(append(prefix, '['), b...)
+			first = false
+		} else {
+			b = append([]byte{','}, b...)
+		}
+		return s.write(b, true)
+	})
+	if err != nil {
+		return err
+	}
+
+	if first {
+		suffix = append(append(prefix, '[', ']'), suffix...)
+	} else {
+		suffix = append([]byte{']'}, suffix...)
+	}
+	if err = s.write(suffix, false); err != nil {
+		return err
+	}
+	return s.flush()
+}
+
+// The placeholder of the data of the envelope, a string that does not occur in the envelope
+const streamDataPlaceholder = "\x00devtool-stream-data\x00"
+
+// Split the JSON of the success response around its data, returns the path of the data before renaming, such as data
+func splitStreamEnvelope(a InterfaceApiData) (prefix, suffix []byte, path string, err error) {
+	envelope := a.WithData(streamDataPlaceholder).Success()
+	b, err := json.Marshal(envelope)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	placeholder, _ := json.Marshal(streamDataPlaceholder)
+	i := bytes.Index(b, placeholder)
+	if i < 0 {
+		return nil, nil, "", errors.New("the success response does not contain the data")
+	}
+
+	v, err := toJSONValue(envelope)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	keys, _ := findStreamData(v)
+	// The keys are renamed, the rules of the rows are written with the keys before renaming
+	r := newApiFieldRenamer().reverse()
+	for _, k := range keys {
+		if name, ok := r.nameOf(path, k); ok {
+			k = name
+		}
+		path = joinFieldPath(path, k)
+	}
+	// The parts are copied, so appending to the prefix does not overwrite the suffix
+	return bytes.Clone(b[:i]), bytes.Clone(b[i+len(placeholder):]), path, nil
+}
+
+// The keys leading to the placeholder
+func findStreamData(value interface{}) ([]string, bool) {
+	switch v := value.(type) {
+	case string:
+		return nil, v == streamDataPlaceholder
+	case map[string]interface{}:
+		for k, item := range v {
+			if keys, ok := findStreamData(item); ok {
+				return append([]string{k}, keys...), true
+			}
+		}
+	}
+	return nil, false
+}
+
+// Writer of a stream, the headers are written before the first bytes
+type stream struct {
+	c            *gin.Context
+	controller   *http.ResponseController
+	contentType  string
+	flushRows    int
+	writeTimeout time.Duration
+	rows         int // The rows written since the last flush
+}
+
+// Write b, row tells whether b is a row, the rows are flushed every stream.flush_rows rows
+func (s *stream) write(b []byte, row bool) error {
+	if !s.c.Writer.Written() {
+		s.c.Header("Content-Type", s.contentType)
+		s.c.Header("X-Content-Type-Options", "nosniff")
+		s.c.Status(http.StatusOK)
+	}
+	if _, err := s.c.Writer.Write(b); err != nil {
+		return err
+	}
+	if row {
+		s.rows++
+		if s.flushRows <= 0 || s.rows >= s.flushRows {
+			return s.flush()
+		}
+	}
+	return nil
+}
+
+// Clear the write deadline, so it does not apply to the next request of the connection
+func (s *stream) close() {
+	if s.writeTimeout > 0 {
+		_ = s.controller.SetWriteDeadline(time.Time{})
+	}
+}
+
+// Send the written bytes to the client, blocks until the client reads them or stream.write_timeout is exceeded
+func (s *stream) flush() error {
+	s.rows = 0
+	if s.writeTimeout > 0 {
+		// Not every writer supports deadlines, such as httptest.ResponseRecorder
+		if err := s.controller.SetWriteDeadline(time.Now().Add(s.writeTimeout)); err != nil && !errors.Is(err, http.ErrNotSupported) {
+			return err
+		}
+	}
+	if err := s.controller.Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		return err
+	}
+	// A disconnected client cancels the context of the request
+	return s.c.Request.Context().Err()
+}
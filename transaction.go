@@ -0,0 +1,114 @@
+package d
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Optional dialect interface, implement it so WithTx retries the transaction on errors such as deadlocks
+type InterfaceDialectRetryable interface {
+	IsRetryableError(err error) bool
+}
+
+const (
+	ConfigPathDatabaseTxMaxRetries       = "database.tx_max_retries"
+	ConfigPathDatabaseTxRetryInterval    = "database.tx_retry_interval"     // Milliseconds, the interval after the first failed attempt
+	ConfigPathDatabaseTxRetryMaxInterval = "database.tx_retry_max_interval" // Milliseconds
+)
+
+var (
+	DefaultDatabaseTxMaxRetries       = 3
+	DefaultDatabaseTxRetryInterval    = 50
+	DefaultDatabaseTxRetryMaxInterval = 1000
+)
+
+type tx_context_key struct{}
+
+// Transaction carried by the context
+type tx_context struct {
+	tx          *gorm.DB
+	afterCommit *[]func(ctx context.Context) // Shared by the nested transactions
+}
+
+// Run fn in a transaction, the transaction is carried by the context passed to fn
+// A nested call with that context uses a savepoint, the outermost call retries on deadlocks and lock wait timeouts
+// Example:
+//
+//	err := d.LibraryGorm{}.WithTx(ctx, func(ctx context.Context, tx *gorm.DB) error {
+//		d.LibraryGorm{}.AfterCommit(ctx, func(ctx context.Context) { sendEmail() })
+//		return tx.Create(&user).Error
+//	})
+func (l LibraryGorm) WithTx(ctx context.Context, fn func(ctx context.Context, tx *gorm.DB) error) error {
+	// Nested call, gorm uses a savepoint for a transaction inside a transaction
+	if tc, ok := ctx.Value(tx_context_key{}).(*tx_context); ok {
+		hooks := len(*tc.afterCommit)
+		err := tc.tx.Transaction(func(tx *gorm.DB) error {
+			return fn(context.WithValue(ctx, tx_context_key{}, &tx_context{tx: tx, afterCommit: tc.afterCommit}), tx)
+		})
+		// The hooks of a rolled back savepoint are dropped
+		if err != nil {
+			*tc.afterCommit = (*tc.afterCommit)[:hooks]
+		}
+		return err
+	}
+
+	conf := Config[InterfaceConfig]{}.Get()
+	maxRetries := conf.GetIntWithDefault(ConfigPathDatabaseTxMaxRetries, DefaultDatabaseTxMaxRetries)
+	interval := time.Millisecond * time.Duration(conf.GetIntWithDefault(ConfigPathDatabaseTxRetryInterval, DefaultDatabaseTxRetryInterval))
+	maxInterval := time.Millisecond * time.Duration(conf.GetIntWithDefault(ConfigPathDatabaseTxRetryMaxInterval, DefaultDatabaseTxRetryMaxInterval))
+
+	db := Database[LibraryGorm]{}.Get().DB.WithContext(ctx)
+	for attempt := 1; ; attempt++ {
+		var hooks []func(ctx context.Context)
+		err := db.Transaction(func(tx *gorm.DB) error {
+			return fn(context.WithValue(ctx, tx_context_key{}, &tx_context{tx: tx, afterCommit: &hooks}), tx)
+		})
+		if err == nil {
+			for _, hook := range hooks {
+				hook(ctx)
+			}
+			return nil
+		}
+
+		if attempt > maxRetries || !l.isRetryableError(db, err) {
+			return err
+		}
+		timer := time.NewTimer(l.backoff(interval, maxInterval, attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+// Register a hook that runs after the outermost transaction of the context is committed
+// Without a transaction in the context, the hook runs immediately
+func (l LibraryGorm) AfterCommit(ctx context.Context, hook func(ctx context.Context)) {
+	tc, ok := ctx.Value(tx_context_key{}).(*tx_context)
+	if !ok {
+		hook(ctx)
+		return
+	}
+	*tc.afterCommit = append(*tc.afterCommit, hook)
+}
+
+// Get the transaction carried by the context, or the registered database if there is none
+func (l LibraryGorm) FromContext(ctx context.Context) *gorm.DB {
+	if tc, ok := ctx.Value(tx_context_key{}).(*tx_context); ok {
+		return tc.tx
+	}
+	return Database[LibraryGorm]{}.Get().DB.WithContext(ctx)
+}
+
+func (l LibraryGorm) isRetryableError(db *gorm.DB, err error) bool {
+	dialect, e := GetDatabaseDialect(db.Dialector.Name())
+	if e != nil {
+		return false
+	}
+	r, ok := dialect.(InterfaceDialectRetryable)
+	return ok && r.IsRetryableError(err)
+}
@@ -0,0 +1,195 @@
+package d
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+const (
+	ConfigPathApiLanguage = "api.language" // The language of the validation messages when the request does not ask for a supported one
+)
+
+var (
+	DefaultApiLanguage = "en"
+)
+
+var (
+	validationMessagesMutex sync.RWMutex
+	// Validation messages by language and rule, {field} and {param} are replaced by the field name and the rule parameter
+	// The empty rule is the message of the rules without a message
+	validationMessages = map[string]map[string]string{
+		"en": {
+			"":         "{field} is invalid",
+			"required": "{field} is required",
+			"email":    "{field} must be a valid email address",
+			"url":      "{field} must be a valid URL",
+			"uuid":     "{field} must be a valid UUID",
+			"numeric":  "{field} must be numeric",
+			"len":      "{field} must have a length of {param}",
+			"min":      "{field} must be at least {param}",
+			"max":      "{field} must be at most {param}",
+			"gt":       "{field} must be greater than {param}",
+			"gte":      "{field} must be greater than or equal to {param}",
+			"lt":       "{field} must be less than {param}",
+			"lte":      "{field} must be less than or equal to {param}",
+			"oneof":    "{field} must be one of [{param}]",
+			"eqfield":  "{field} must be equal to {param}",
+		},
+		"zh": {
+			"":         "{field}格式不正确",
+			"required": "{field}为必填字段",
+			"email":    "{field}必须是一个有效的邮箱",
+			"url":      "{field}必须是一个有效的URL",
+			"uuid":     "{field}必须是一个有效的UUID",
+			"numeric":  "{field}必须是一个有效的数值",
+			"len":      "{field}长度必须是{param}",
+			"min":      "{field}最小只能为{param}",
+			"max":      "{field}最大只能为{param}",
+			"gt":       "{field}必须大于{param}",
+			"gte":      "{field}必须大于或等于{param}",
+			"lt":       "{field}必须小于{param}",
+			"lte":      "{field}必须小于或等于{param}",
+			"oneof":    "{field}必须是[{param}]中的一个",
+			"eqfield":  "{field}必须等于{param}",
+		},
+	}
+)
+
+// Register the validation message of a rule in a language, an existing message is replaced
+// Example: d.RegisterValidationMessage("en", "mobile", "{field} must be a valid mobile number")
+func RegisterValidationMessage(lang, rule, message string) {
+	validationMessagesMutex.Lock()
+	defer validationMessagesMutex.Unlock()
+	lang = strings.ToLower(lang)
+	if validationMessages[lang] == nil {
+		validationMessages[lang] = make(map[string]string)
+	}
+	validationMessages[lang][rule] = message
+}
+
+// Convert the validation errors to field errors, returns nil if err is not validator.ValidationErrors
+// The field names are the JSON names of the fields of obj, renamed by the api.field and api.casing config, obj may be nil
+// The messages are in lang, such as the Accept-Language header, the api.language config is used if lang is not supported
+// Example: fields := d.ValidationFieldErrors(err, &user, c.GetHeader("Accept-Language"))
+func ValidationFieldErrors(err error, obj interface{}, lang string) []ApiFieldError {
+	var validationErrs validator.ValidationErrors
+	if !errors.As(err, &validationErrs) {
+		return nil
+	}
+
+	r := newApiFieldRenamer()
+	messages := validationMessagesOf(lang)
+
+	var t reflect.Type
+	if obj != nil {
+		t = reflect.TypeOf(obj)
+	}
+
+	list := make([]ApiFieldError, 0, len(validationErrs))
+	for _, v := range validationErrs {
+		field := validationFieldName(v, t, r)
+		message, ok := messages[v.Tag()]
+		if !ok {
+			message = messages[""]
+		}
+		message = strings.NewReplacer("{field}", field, "{param}", v.Param()).Replace(message)
+		list = append(list, ApiFieldError{Field: field, Rule: v.Tag(), Param: v.Param(), Message: message})
+	}
+	return list
+}
+
+// Create a 422 API error from the validation errors, returns nil if err is not validator.ValidationErrors
+func NewValidationApiError(err error, obj interface{}, lang string) *ApiError {
+	fields := ValidationFieldErrors(err, obj, lang)
+	if fields == nil {
+		return nil
+	}
+	apiErr := NewApiError(http.StatusUnprocessableEntity, "")
+	apiErr.Fields = fields
+	return apiErr
+}
+
+// The language of the request, the first language of the Accept-Language header
+func requestLanguage(c *gin.Context) string {
+	if c == nil || c.Request == nil {
+		return ""
+	}
+	lang, _, _ := strings.Cut(c.GetHeader("Accept-Language"), ",")
+	lang, _, _ = strings.Cut(lang, ";")
+	return strings.TrimSpace(lang)
+}
+
+// Find the messages of a language, zh-CN falls back to zh, then to the api.language config and en
+func validationMessagesOf(lang string) map[string]string {
+	validationMessagesMutex.RLock()
+	defer validationMessagesMutex.RUnlock()
+
+	lang = strings.ToLower(strings.ReplaceAll(lang, "_", "-"))
+	base, _, _ := strings.Cut(lang, "-")
+	for _, v := range []string{lang, base, Config[InterfaceConfig]{}.Get().GetStringWithDefault(ConfigPathApiLanguage, DefaultApiLanguage), DefaultApiLanguage} {
+		if m, ok := validationMessages[strings.ToLower(v)]; ok {
+			return m
+		}
+	}
+	return validationMessages["en"]
+}
+
+// The path of the field, such as address.street or items[0].name
+// Without obj, the namespace reported by the validator is used
+func validationFieldName(e validator.FieldError, t reflect.Type, r fieldRenamer) string {
+	// The first part of the namespace is the name of the struct
+	_, namespace, _ := strings.Cut(e.StructNamespace(), ".")
+	if t == nil {
+		_, namespace, _ = strings.Cut(e.Namespace(), ".")
+	}
+
+	var names []string
+	var path string // The path of the JSON names before renaming
+	for _, part := range strings.Split(namespace, ".") {
+		name, index, _ := strings.Cut(part, "[")
+		if len(index) > 0 {
+			index = "[" + index
+		}
+
+		if t != nil {
+			for t.Kind() == reflect.Pointer || t.Kind() == reflect.Slice || t.Kind() == reflect.Array || t.Kind() == reflect.Map {
+				t = t.Elem()
+			}
+			var f reflect.StructField
+			var ok bool
+			if t.Kind() == reflect.Struct {
+				f, ok = t.FieldByName(name)
+			}
+			if ok {
+				t = f.Type
+				jsonName := strings.Split(f.Tag.Get("json"), ",")[0]
+				// The fields of an embedded struct are promoted in JSON
+				if f.Anonymous && jsonName == "" {
+					continue
+				}
+				if jsonName != "" && jsonName != "-" {
+					name = jsonName
+				}
+			} else {
+				t = nil
+			}
+		}
+
+		p := joinFieldPath(path, name)
+		if v, ok := r.nameOf(path, name); ok {
+			name = v
+		}
+		if len(index) > 0 {
+			p += "[]"
+		}
+		path = p
+		names = append(names, name+index)
+	}
+	return strings.Join(names, ".")
+}